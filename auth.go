@@ -0,0 +1,20 @@
+package signups
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ADMIN_TOKEN authorizes requests to the service's non-public endpoints. Those endpoints reject every request when it is not set.
+var ADMIN_TOKEN = os.Getenv("ADMIN_TOKEN")
+
+// authorized reports whether the request carries the admin token as a Bearer token.
+func authorized(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if ADMIN_TOKEN == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(ADMIN_TOKEN)) == 1
+}
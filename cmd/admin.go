@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// adminRequest sends a request with the admin token to a deployed endpoint and returns the response body.
+// It exits if the request fails.
+func adminRequest(method, endpoint, token string, body io.Reader) []byte {
+	req, err := http.NewRequest(method, endpoint, body)
+	if err != nil {
+		log.Fatalf("%s %s: %v\n", method, endpoint, err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Fatalf("%s %s: %v\n", method, endpoint, err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Fatalf("%s %s: %v\n", method, endpoint, err)
+	}
+	if resp.StatusCode >= 300 {
+		log.Fatalf("%s %s: %s\n%s", method, endpoint, resp.Status, b)
+	}
+	return b
+}
+
+// parseTime parses a -since or -until flag with signups.ParseSince or signups.ParseUntil. An empty flag is the zero time.
+func parseTime(value string, parse func(string) (time.Time, error)) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	t, err := parse(value)
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+	return t
+}
+
+// splitList splits a comma-separated flag, ignoring empty items.
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// printJSON writes v to stdout as indented JSON.
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Fatalf("writing JSON: %v\n", err)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	signups "github.com/operationspark/slack-session-signups"
+	"github.com/operationspark/slack-session-signups/email/mailguntest"
+	"github.com/operationspark/slack-session-signups/greenlighttest"
+	"github.com/operationspark/slack-session-signups/slack/slacktest"
+)
+
+// devTraffic is everything the fake downstream services have received.
+type devTraffic struct {
+	Greenlight []greenlighttest.Request `json:"greenlight"`
+	Slack      []devSlackCall           `json:"slack"`
+	Mailgun    []mailguntest.Message    `json:"mailgun"`
+}
+
+// devSlackCall is a slacktest.Call with its JSON body shown as JSON.
+type devSlackCall struct {
+	slacktest.Call
+	Body json.RawMessage
+}
+
+// dev starts the local function server with fake Greenlight, Slack, and Mailgun servers in the same process,
+// so the whole signup flow can be run offline. The fakes' traffic is served as JSON at /dev/traffic.
+func dev(args []string) {
+	fs := flag.NewFlagSet("dev", flag.ExitOnError)
+	fs.Parse(args)
+
+	greenlight := greenlighttest.NewServer()
+	defer greenlight.Close()
+	slackSrv := slacktest.NewServer()
+	defer slackSrv.Close()
+	mailgun := mailguntest.NewServer()
+	defer mailgun.Close()
+
+	// Point every downstream at the fakes, including the settings read at startup
+	for key, value := range map[string]string{
+		"GREENLIGHT_WEBHOOK_URL":          greenlight.WebhookURL(),
+		"WORKSHOP_GREENLIGHT_WEBHOOK_URL": "",
+		"WORKSHOP_SLACK_WEBHOOK_URL":      slackSrv.WebhookURL("workshops"),
+		"SLACK_CHANNEL_ID":                "C0SIGNUPS",
+		"WORKSHOP_SLACK_CHANNEL_ID":       "C0WORKSHOPS",
+		"MAIL_DOMAIN":                     "mail.dev.local",
+		"MAIL_GUN_PRIVATE_API_KEY":        "dev-mailgun-key",
+		"MAILGUN_API_BASE":                mailgun.APIURL(),
+		"DISABLE_GREENLIGHT":              "",
+		"DISABLE_SLACK":                   "",
+	} {
+		os.Setenv(key, value)
+	}
+	signups.SLACK_WEBHOOK_URL = slackSrv.WebhookURL("signups")
+	signups.SLACK_BOT_TOKEN = "xoxb-dev"
+	signups.SLACK_API_URL = slackSrv.APIURL()
+	signups.SLACK_ROUTES = ""
+	signups.DIGEST_SLACK_DESTINATION = slackSrv.WebhookURL("digest")
+	signups.UNSUBSCRIBE_URL = fmt.Sprintf("http://localhost:%s/unsubscribe", port())
+	signups.UNSUBSCRIBE_SECRET = "dev"
+	if signups.ADMIN_TOKEN == "" {
+		signups.ADMIN_TOKEN = "dev"
+	}
+
+	mux := functionsMux()
+	mux.HandleFunc("/dev/traffic", func(w http.ResponseWriter, r *http.Request) {
+		traffic := devTraffic{Greenlight: greenlight.Requests(), Slack: []devSlackCall{}, Mailgun: mailgun.Messages()}
+		for _, c := range slackSrv.Calls() {
+			traffic.Slack = append(traffic.Slack, devSlackCall{Call: c, Body: c.Body})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(traffic); err != nil {
+			log.Printf("writing dev traffic: %v\n", err)
+		}
+	})
+
+	fmt.Printf("Fake Greenlight: %s\nFake Slack:      %s\nFake Mailgun:    %s\n", greenlight.URL, slackSrv.URL, mailgun.URL)
+	fmt.Printf("Admin token:     %s\n", signups.ADMIN_TOKEN)
+	fmt.Printf("Serving functions on port %s. Downstream traffic: http://localhost:%s/dev/traffic\n", port(), port())
+	log.Fatal(http.ListenAndServe(":"+port(), mux))
+}
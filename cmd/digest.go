@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	signups "github.com/operationspark/slack-session-signups"
+)
+
+// digest posts a signup digest to Slack, either from this process's signup store or by triggering a deployed digest endpoint.
+func digest(args []string) {
+	fs := flag.NewFlagSet("digest", flag.ExitOnError)
+	period := fs.String("period", "daily", "digest period: 'daily' or 'weekly'")
+	dryRun := fs.Bool("dry-run", false, "print the digest instead of posting it to Slack")
+	endpoint := fs.String("url", "", "digest endpoint to trigger, e.g. https://[function URL]/digest. Runs locally when empty")
+	token := fs.String("token", os.Getenv("ADMIN_TOKEN"), "admin token for the digest endpoint")
+	fs.Parse(args)
+
+	if *endpoint == "" {
+		msg, err := signups.RunDigest(context.Background(), *period, time.Now(), *dryRun)
+		if err != nil {
+			log.Fatalf("digest: %v\n", err)
+		}
+		printJSON(msg)
+		return
+	}
+
+	u, err := url.Parse(*endpoint)
+	if err != nil {
+		log.Fatalf("digest: invalid -url: %v\n", err)
+	}
+	q := u.Query()
+	q.Set("period", *period)
+	q.Set("dryRun", strconv.FormatBool(*dryRun))
+	u.RawQuery = q.Encode()
+
+	fmt.Println(string(adminRequest(http.MethodPost, u.String(), *token, nil)))
+}
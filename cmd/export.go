@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	signups "github.com/operationspark/slack-session-signups"
+)
+
+// export writes stored signups to stdout as CSV or NDJSON, either from this process's signup store (SIGNUP_DB_PATH)
+// or through a deployed export endpoint.
+func export(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	cohort := fs.String("cohort", "", "export signups for this cohort, e.g. is-mar-14-22-12pm")
+	sessionId := fs.String("session", "", "export signups for this Greenlight session ID")
+	program := fs.String("program", "", "export signups for this program, e.g. info-session or workshop")
+	since := fs.String("since", "", "export signups received at or after this date or RFC 3339 time")
+	until := fs.String("until", "", "export signups received through this date, or before this RFC 3339 time")
+	format := fs.String("format", "csv", "'csv' or 'ndjson'")
+	columns := fs.String("columns", "", "comma-separated columns to export: "+strings.Join(signups.ExportColumnNames(), ","))
+	redact := fs.Bool("redact", false, "hide last names, email addresses, and phone numbers")
+	endpoint := fs.String("url", "", "export endpoint, e.g. https://[function URL]/admin/export. Uses the local signup store when empty")
+	token := fs.String("token", os.Getenv("ADMIN_TOKEN"), "admin token for the export endpoint")
+	fs.Parse(args)
+
+	opts := signups.ExportOptions{
+		Cohort:    *cohort,
+		SessionId: *sessionId,
+		ProgramId: *program,
+		Since:     parseTime(*since, signups.ParseSince),
+		Until:     parseTime(*until, signups.ParseUntil),
+		Format:    *format,
+		Columns:   splitList(*columns),
+		Redact:    *redact,
+	}
+
+	if *endpoint == "" {
+		_, err := signups.Export(context.Background(), os.Stdout, opts)
+		if err != nil {
+			log.Fatalf("export: %v\n", err)
+		}
+		return
+	}
+
+	u, err := url.Parse(*endpoint)
+	if err != nil {
+		log.Fatalf("export: invalid -url: %v\n", err)
+	}
+	q := u.Query()
+	for name, value := range map[string]string{"cohort": *cohort, "sessionId": *sessionId, "program": *program, "since": *since, "until": *until, "format": *format, "columns": *columns} {
+		if value != "" {
+			q.Set(name, value)
+		}
+	}
+	if *redact {
+		q.Set("redact", "true")
+	}
+	u.RawQuery = q.Encode()
+	os.Stdout.Write(adminRequest(http.MethodGet, u.String(), *token, nil))
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	signups "github.com/operationspark/slack-session-signups"
+)
+
+// importSignups backfills signups collected outside operationspark.org (e.g. in a Google Sheet) from a CSV or JSON file.
+// Each row is parsed like a signup from the website, then delivered to Greenlight, Slack, and email and stored in SIGNUP_DB_PATH.
+func importSignups(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	format := fs.String("format", "", "'csv' or 'json'. Defaults to the file's extension")
+	columns := fs.String("map", "", "comma-separated column mappings onto Signup fields, e.g. 'Your Name=nameFirst,Mobile=cell'")
+	rate := fs.Float64("rate", 1, "maximum signups delivered per second")
+	dryRun := fs.Bool("dry-run", false, "validate the file without delivering or storing anything")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: go run . import [flags] <file>\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	path := fs.Arg(0)
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+	if *rate <= 0 {
+		log.Fatalf("import: -rate must be positive\n")
+	}
+
+	mapping := map[string]string{}
+	for _, m := range splitList(*columns) {
+		parts := strings.SplitN(m, "=", 2)
+		if len(parts) != 2 {
+			log.Fatalf("import: invalid -map %q: use 'Column=field'\n", m)
+		}
+		mapping[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("import: %v\n", err)
+	}
+	defer f.Close()
+	rows, unmapped, err := signups.ReadImport(f, *format, mapping)
+	if err != nil {
+		log.Fatalf("import: %v\n", err)
+	}
+	if len(unmapped) > 0 {
+		fmt.Fprintf(os.Stderr, "Ignoring columns: %s (map them with -map)\n", strings.Join(unmapped, ", "))
+	}
+
+	opts := signups.ImportOptions{Interval: time.Duration(float64(time.Second) / *rate), DryRun: *dryRun}
+	results, err := signups.Import(context.Background(), rows, opts)
+	counts := map[string]int{}
+	for _, r := range results {
+		fmt.Println(r)
+		counts[r.Result]++
+	}
+	if err != nil {
+		log.Fatalf("import: %v\n", err)
+	}
+	summary := []string{}
+	for _, result := range []string{"imported", "would import", "skipped", "failed", "invalid"} {
+		if counts[result] > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", counts[result], result))
+		}
+	}
+	fmt.Printf("%d rows: %s\n", len(results), strings.Join(summary, ", "))
+}
@@ -1,25 +1,92 @@
 package main
 
 import (
-	"context"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
 
-	"github.com/GoogleCloudPlatform/functions-framework-go/funcframework"
 	signups "github.com/operationspark/slack-session-signups"
 )
 
 func main() {
-	ctx := context.Background()
-	if err := funcframework.RegisterHTTPFunctionContext(ctx, "/", signups.HandleSignUp); err != nil {
-		log.Fatalf("funcframework.RegisterHTTPFunctionContext: %v\n", err)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "preview":
+			preview(os.Args[2:])
+			return
+		case "digest":
+			digest(os.Args[2:])
+			return
+		case "replay":
+			replay(os.Args[2:])
+			return
+		case "export":
+			export(os.Args[2:])
+			return
+		case "import":
+			importSignups(os.Args[2:])
+			return
+		case "send":
+			send(os.Args[2:])
+			return
+		case "dev":
+			dev(os.Args[2:])
+			return
+		}
 	}
-	// Use PORT environment variable, or default to 8080.
-	port := "8080"
-	if envPort := os.Getenv("PORT"); envPort != "" {
-		port = envPort
+	serve()
+}
+
+// serve starts the local function server with every endpoint.
+func serve() {
+	fmt.Printf("Serving functions on port %s\n", port())
+	log.Fatal(http.ListenAndServe(":"+port(), functionsMux()))
+}
+
+// functionsMux serves every function at its endpoint path.
+// funcframework only serves the last function registered, so the endpoints are served with their own mux.
+func functionsMux() *http.ServeMux {
+	handlers := map[string]func(http.ResponseWriter, *http.Request){
+		"/":                   signups.HandleSignUp,
+		"/v1/signups":         signups.HandleSignUp,
+		"/openapi.json":       signups.HandleOpenAPI,
+		"/healthz":            signups.HandleHealth,
+		"/readyz":             signups.HandleReady,
+		"/version":            signups.HandleVersion,
+		"/events":             signups.HandleSignupEvent,
+		"/slack/interactions": signups.HandleSlackInteraction,
+		"/slack/commands":     signups.HandleSlashCommand,
+		"/digest":             signups.HandleDigest,
+		"/admin/replay":       signups.HandleReplay,
+		"/admin/export":       signups.HandleExport,
+		"/reports/referrals":  signups.HandleReferralReport,
+		"/mailgun/events":     signups.HandleEmailEvent,
+		"/unsubscribe":        signups.HandleUnsubscribe,
+	}
+	mux := http.NewServeMux()
+	for path, fn := range handlers {
+		mux.HandleFunc(path, recoverPanics(fn))
 	}
-	if err := funcframework.Start(port); err != nil {
-		log.Fatalf("funcframework.Start: %v\n", err)
+	return mux
+}
+
+// recoverPanics logs a panicking function's error instead of crashing the server, like Cloud Functions does.
+func recoverPanics(fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				log.Printf("%s %s: function panic: %v\n", r.Method, r.URL.Path, err)
+			}
+		}()
+		fn(w, r)
+	}
+}
+
+// port returns the PORT environment variable, or defaults to 8080.
+func port() string {
+	if envPort := os.Getenv("PORT"); envPort != "" {
+		return envPort
 	}
+	return "8080"
 }
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	signups "github.com/operationspark/slack-session-signups"
+)
+
+// preview serves every registered email template, rendered with fixture Signups, for viewing in the browser.
+func preview(args []string) {
+	fs := flag.NewFlagSet("preview", flag.ExitOnError)
+	port := fs.String("port", port(), "port to serve previews on")
+	fs.Parse(args)
+
+	log.Printf("Serving email previews on http://localhost:%s\n", *port)
+	if err := http.ListenAndServe(":"+*port, signups.PreviewHandler()); err != nil {
+		log.Fatalf("http.ListenAndServe: %v\n", err)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	signups "github.com/operationspark/slack-session-signups"
+)
+
+// replay redelivers stored signups to Greenlight, Slack, and/or email, either from this process's signup store (SIGNUP_DB_PATH)
+// or through a deployed replay endpoint. Targets a signup was already delivered to are skipped.
+func replay(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	since := fs.String("since", "", "replay signups received at or after this date or RFC 3339 time")
+	until := fs.String("until", "", "replay signups received through this date, or before this RFC 3339 time")
+	status := fs.String("status", "", "replay signups whose latest delivery has this status: 'failed', 'pending', or 'delivered'")
+	downstream := fs.String("downstream", "", "with -status, the target whose status is checked: 'greenlight', 'slack', or 'email'. Any target when empty")
+	targets := fs.String("targets", "", "comma-separated targets to redeliver to (greenlight,slack,email). All targets when empty")
+	dryRun := fs.Bool("dry-run", false, "print what would be redelivered without sending anything")
+	endpoint := fs.String("url", "", "replay endpoint, e.g. https://[function URL]/admin/replay. Uses the local signup store when empty")
+	token := fs.String("token", os.Getenv("ADMIN_TOKEN"), "admin token for the replay endpoint")
+	fs.Parse(args)
+
+	opts := signups.ReplayOptions{
+		Since:      parseTime(*since, signups.ParseSince),
+		Until:      parseTime(*until, signups.ParseUntil),
+		Status:     *status,
+		Downstream: *downstream,
+		Targets:    splitList(*targets),
+		DryRun:     *dryRun,
+	}
+
+	var results []signups.ReplayResult
+	if *endpoint == "" {
+		var err error
+		results, err = signups.Replay(context.Background(), opts)
+		if err != nil {
+			log.Fatalf("replay: %v\n", err)
+		}
+	} else {
+		body, err := json.Marshal(opts)
+		if err != nil {
+			log.Fatalf("replay: %v\n", err)
+		}
+		resp := adminRequest(http.MethodPost, *endpoint, *token, bytes.NewReader(body))
+		if err := json.Unmarshal(resp, &results); err != nil {
+			log.Fatalf("replay: reading response: %v\n", err)
+		}
+	}
+
+	for _, r := range results {
+		fmt.Println(r)
+	}
+	if len(results) == 0 {
+		fmt.Println("No signups matched.")
+	}
+}
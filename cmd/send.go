@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	signups "github.com/operationspark/slack-session-signups"
+)
+
+// send submits a test signup to a local or deployed signup endpoint and prints the response, including each delivery's result.
+// The signup starts from a preview fixture or a JSON fixture file, and flags override its fields.
+func send(args []string) {
+	fs := flag.NewFlagSet("send", flag.ExitOnError)
+	endpoint := fs.String("url", "http://localhost:"+port()+"/v1/signups", "signup endpoint")
+	form := fs.Bool("form", false, "send the signup form-encoded, like the website's form, instead of as JSON")
+	fixture := fs.String("fixture", "with-session", "preview fixture to start from ("+strings.Join(fixtureNames(), ", ")+") or a JSON file with a Signup")
+
+	// Signup fields that can be set with flags
+	fields := []struct {
+		name, usage string
+		set         func(s *signups.Signup, v string) error
+	}{
+		{"program", "program ID, e.g. info-session or workshop", func(s *signups.Signup, v string) error { s.ProgramId = v; return nil }},
+		{"first", "first name", func(s *signups.Signup, v string) error { s.NameFirst = v; return nil }},
+		{"last", "last name", func(s *signups.Signup, v string) error { s.NameLast = v; return nil }},
+		{"email", "email address", func(s *signups.Signup, v string) error { s.Email = v; return nil }},
+		{"cell", "phone number", func(s *signups.Signup, v string) error { s.Cell = v; return nil }},
+		{"referrer", "how they heard about Operation Spark", func(s *signups.Signup, v string) error { s.Referrer = v; return nil }},
+		{"referrer-response", "referrer details, e.g. who referred them", func(s *signups.Signup, v string) error { s.ReferrerResponse = v; return nil }},
+		{"cohort", "session cohort, e.g. is-mar-14-22-12pm", func(s *signups.Signup, v string) error { s.Cohort = v; return nil }},
+		{"session-id", "Greenlight session ID", func(s *signups.Signup, v string) error { s.SessionId = v; return nil }},
+		{"start", "session start (RFC 3339), or 'none' to request information instead", func(s *signups.Signup, v string) error {
+			if v == "none" {
+				s.StartDateTime = time.Time{}
+				return nil
+			}
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return fmt.Errorf("invalid -start %q: use an RFC 3339 time, e.g. 2022-03-14T12:00:00-05:00", v)
+			}
+			s.StartDateTime = t
+			return nil
+		}},
+		{"utm-source", "UTM source", func(s *signups.Signup, v string) error { s.UtmSource = v; return nil }},
+		{"utm-medium", "UTM medium", func(s *signups.Signup, v string) error { s.UtmMedium = v; return nil }},
+		{"utm-campaign", "UTM campaign", func(s *signups.Signup, v string) error { s.UtmCampaign = v; return nil }},
+		{"landing-page", "landing page URL", func(s *signups.Signup, v string) error { s.LandingPage = v; return nil }},
+	}
+	for _, f := range fields {
+		fs.String(f.name, "", f.usage)
+	}
+	fs.Parse(args)
+
+	signup := loadFixture(*fixture)
+	// Flags override the fixture's fields
+	fs.Visit(func(f *flag.Flag) {
+		for _, field := range fields {
+			if field.name != f.Name {
+				continue
+			}
+			if err := field.set(&signup, f.Value.String()); err != nil {
+				log.Fatalf("send: %v\n", err)
+			}
+		}
+	})
+
+	var body io.Reader
+	contentType := "application/json"
+	if *form {
+		contentType = "application/x-www-form-urlencoded"
+		body = strings.NewReader(signupForm(signup).Encode())
+	} else {
+		b, err := json.Marshal(signup)
+		if err != nil {
+			log.Fatalf("send: %v\n", err)
+		}
+		body = bytes.NewReader(b)
+	}
+
+	resp, err := http.Post(*endpoint, contentType, body)
+	if err != nil {
+		log.Fatalf("send: %v\n", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Fatalf("send: reading response: %v\n", err)
+	}
+
+	fmt.Println(resp.Status)
+	var result signups.SignupResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		// Not a structured response, e.g. from an older deployment
+		os.Stdout.Write(respBody)
+	} else {
+		printJSON(result)
+	}
+	if resp.StatusCode >= 300 {
+		os.Exit(1)
+	}
+}
+
+// fixtureNames returns the names of the preview fixtures.
+func fixtureNames() []string {
+	names := []string{}
+	for _, f := range signups.PreviewFixtures() {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+// loadFixture returns the named preview fixture's Signup, or reads a Signup from the JSON file.
+func loadFixture(name string) signups.Signup {
+	for _, f := range signups.PreviewFixtures() {
+		if f.Name == name {
+			return f.Signup
+		}
+	}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		log.Fatalf("send: unknown fixture %q: use one of %s, or a JSON file\n", name, strings.Join(fixtureNames(), ", "))
+	}
+	var s signups.Signup
+	if err := json.Unmarshal(b, &s); err != nil {
+		log.Fatalf("send: reading fixture %s: %v\n", name, err)
+	}
+	return s
+}
+
+// signupForm encodes the Signup as the website's form fields. Empty fields are left out.
+func signupForm(s signups.Signup) url.Values {
+	b, _ := json.Marshal(s)
+	var fields map[string]interface{}
+	json.Unmarshal(b, &fields)
+	form := url.Values{}
+	for k, v := range fields {
+		if str, ok := v.(string); ok && str != "" {
+			form.Set(k, str)
+		}
+	}
+	if s.StartDateTime.IsZero() {
+		form.Del("startDateTime")
+	}
+	return form
+}
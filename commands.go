@@ -0,0 +1,180 @@
+package signups
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/operationspark/slack-session-signups/slack"
+)
+
+// maxListed limits how many signups a slash command lists in one reply.
+const maxListed = 20
+
+const commandUsage = "Usage:\n" +
+	"`/signups today` signups received today\n" +
+	"`/signups session <cohort>` signups for a session, e.g. `is-mar-14-22-12pm`\n" +
+	"`/signups find <email>` every signup from an email address\n" +
+	"`/signups stats week` signup counts for the last 7 days"
+
+// HandleSlashCommand answers the `/signups` Slack slash command from the service's signup store.
+// Replies are only visible to the user who ran the command.
+func HandleSlashCommand(w http.ResponseWriter, r *http.Request) {
+	_, err := slack.VerifyRequest(r, SLACK_SIGNING_SECRET, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	msg, err := signupsCommand(r.Context(), strings.Fields(r.FormValue("text")), time.Now())
+	if err != nil {
+		msg = slack.Message{Text: fmt.Sprintf("Sorry, something went wrong: %s", err)}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(slack.Ephemeral(msg))
+	if err != nil {
+		fmt.Printf("error writing slash command response %s\n", err.Error())
+	}
+}
+
+// signupsCommand runs a `/signups` subcommand and creates the reply.
+func signupsCommand(ctx context.Context, args []string, now time.Time) (slack.Message, error) {
+	if len(args) == 0 {
+		return slack.Message{Text: commandUsage}, nil
+	}
+
+	switch {
+	case args[0] == "today":
+		y, m, d := now.In(centralTZ()).Date()
+		since := time.Date(y, m, d, 0, 0, 0, 0, centralTZ())
+		records, err := signupStore.Find(ctx, Query{Since: since})
+		if err != nil {
+			return slack.Message{}, err
+		}
+		return listMessage(fmt.Sprintf("%s today", countOf(len(records), "signup")), records), nil
+
+	case args[0] == "session" && len(args) == 2:
+		records, err := signupStore.Find(ctx, Query{Cohort: args[1]})
+		if err != nil {
+			return slack.Message{}, err
+		}
+		return listMessage(fmt.Sprintf("%s for %s", countOf(len(records), "signup"), args[1]), records), nil
+
+	case args[0] == "find" && len(args) == 2:
+		// Slack formats email addresses as links: <mailto:henri@email.com|henri@email.com>
+		email := args[1]
+		if i := strings.Index(email, "|"); strings.HasPrefix(email, "<mailto:") && i > 0 {
+			email = strings.TrimSuffix(email[i+1:], ">")
+		}
+		records, err := signupStore.Find(ctx, Query{Email: email})
+		if err != nil {
+			return slack.Message{}, err
+		}
+		return listMessage(fmt.Sprintf("%s from %s", countOf(len(records), "signup"), email), records), nil
+
+	case args[0] == "stats" && len(args) == 2 && args[1] == "week":
+		records, err := signupStore.Find(ctx, Query{Since: now.Add(-7 * 24 * time.Hour)})
+		if err != nil {
+			return slack.Message{}, err
+		}
+		return statsMessage("Signups in the last 7 days", records), nil
+	}
+
+	return slack.Message{Text: commandUsage}, nil
+}
+
+// listMessage creates a message with the title followed by a line for each signup, newest first.
+func listMessage(title string, records []*Record) slack.Message {
+	lines := []string{}
+	for i := len(records) - 1; i >= 0 && len(lines) < maxListed; i-- {
+		lines = append(lines, signupLine(records[i]))
+	}
+	if len(records) > maxListed {
+		lines = append(lines, fmt.Sprintf("_…and %d more_", len(records)-maxListed))
+	}
+
+	blocks := []slack.Block{slack.Section("*" + title + "*")}
+	if len(lines) > 0 {
+		blocks = append(blocks, slack.Section(strings.Join(lines, "\n")))
+	}
+	return slack.Message{Text: title, Blocks: blocks}
+}
+
+// signupLine summarizes a signup on one line.
+func signupLine(rec *Record) string {
+	s := rec.Signup
+	session := s.Cohort
+	if s.StartDateTime.IsZero() {
+		session = "no session selected"
+	}
+	line := fmt.Sprintf("• %s %s (%s) — %s — received %s", s.NameFirst, s.NameLast, s.Email, session, rec.ReceivedAt.In(centralTZ()).Format("Mon Jan 2 3:04 PM"))
+	if rec.AssignedTo != "" {
+		line += fmt.Sprintf(" — assigned to <@%s>", rec.AssignedTo)
+	}
+	return line
+}
+
+// statsMessage creates a message with signup counts by session and program.
+func statsMessage(title string, records []*Record) slack.Message {
+	bySession := map[string]int{}
+	byProgram := map[string]int{}
+	for _, rec := range records {
+		session := rec.Signup.Cohort
+		if rec.Signup.StartDateTime.IsZero() {
+			session = "no session selected"
+		}
+		bySession[session]++
+		byProgram[rec.Signup.Program().Name]++
+	}
+
+	summary := fmt.Sprintf("*%s:* %d", title, len(records))
+	blocks := []slack.Block{slack.Section(summary)}
+	if len(records) > 0 {
+		blocks = append(blocks,
+			slack.Section("*By session*\n"+countLines(bySession)),
+			slack.Section("*By program*\n"+countLines(byProgram)),
+		)
+	}
+	return slack.Message{Text: fmt.Sprintf("%s: %d", title, len(records)), Blocks: blocks}
+}
+
+// countLines lists the counts, largest first.
+func countLines(counts map[string]int) string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] == counts[keys[j]] {
+			return keys[i] < keys[j]
+		}
+		return counts[keys[i]] > counts[keys[j]]
+	})
+	lines := make([]string, len(keys))
+	for i, k := range keys {
+		lines[i] = fmt.Sprintf("• %s: %d", k, counts[k])
+	}
+	return strings.Join(lines, "\n")
+}
+
+// countOf formats a count with a singular or plural noun, e.g. "1 signup" or "3 signups".
+func countOf(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// centralTZ returns Operation Spark's time zone, or UTC if the time zone database is unavailable.
+func centralTZ() *time.Location {
+	ctz, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		return time.UTC
+	}
+	return ctz
+}
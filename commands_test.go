@@ -0,0 +1,110 @@
+package signups
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/operationspark/slack-session-signups/slack"
+)
+
+func TestSignupsCommand(t *testing.T) {
+	defer setStore(NewMemoryStore())()
+	now, _ := time.Parse(time.RFC3339, "2022-03-10T18:00:00Z")
+	sessionStart, _ := time.Parse(time.RFC3339, "2022-03-14T17:00:00Z")
+
+	seed := []struct {
+		signup     Signup
+		receivedAt time.Time
+	}{
+		{Signup{NameFirst: "Henri", NameLast: "Testaroni", Email: "henri@email.com", Cohort: "is-mar-14-22-12pm", StartDateTime: sessionStart}, now.Add(-2 * time.Hour)},
+		{Signup{NameFirst: "Solána", NameLast: "Rowe", Email: "solana@email.com"}, now.Add(-3 * 24 * time.Hour)},
+		{Signup{NameFirst: "Henri", NameLast: "Testaroni", Email: "henri@email.com", Cohort: "is-feb-28-22-12pm", StartDateTime: sessionStart}, now.Add(-10 * 24 * time.Hour)},
+	}
+	for _, s := range seed {
+		rec := newRecord(s.signup)
+		rec.ReceivedAt = s.receivedAt
+		if err := signupStore.Save(context.Background(), rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		text    string
+		want    []string
+		notWant []string
+	}{
+		{"today", []string{"1 signup today", "Henri Testaroni (henri@email.com) — is-mar-14-22-12pm"}, []string{"Solána"}},
+		{"session IS-MAR-14-22-12PM", []string{"1 signup for IS-MAR-14-22-12PM"}, []string{"is-feb-28-22-12pm"}},
+		{"find <mailto:henri@email.com|henri@email.com>", []string{"2 signups from henri@email.com", "is-feb-28-22-12pm", "is-mar-14-22-12pm"}, nil},
+		{"stats week", []string{"Signups in the last 7 days:* 2", "• is-mar-14-22-12pm: 1", "• no session selected: 1", "• Info Session: 2"}, nil},
+		{"", []string{"Usage:"}, nil},
+		{"session", []string{"Usage:"}, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			msg, err := signupsCommand(context.Background(), strings.Fields(test.text), now)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			got := messageText(msg)
+			for _, want := range test.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("reply missing %q\n%s", want, got)
+				}
+			}
+			for _, notWant := range test.notWant {
+				if strings.Contains(got, notWant) {
+					t.Errorf("reply should not contain %q\n%s", notWant, got)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleSlashCommand(t *testing.T) {
+	defer setVar(&SLACK_SIGNING_SECRET, "signing-secret")()
+	defer setStore(NewMemoryStore())()
+
+	body := url.Values{"command": {"/signups"}, "text": {"today"}}.Encode()
+	w := httptest.NewRecorder()
+	HandleSlashCommand(w, signedSlackRequest("signing-secret", body))
+	if w.Code != http.StatusOK {
+		t.Fatalf("want status 200, got %d", w.Code)
+	}
+	var got slack.CommandResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ResponseType != "ephemeral" || got.Text != "0 signups today" {
+		t.Errorf("unexpected reply %+v", got)
+	}
+
+	w = httptest.NewRecorder()
+	HandleSlashCommand(w, signedSlackRequest("wrong-secret", body))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("want status 401 for an unsigned request, got %d", w.Code)
+	}
+}
+
+// messageText joins the text of the message and its section and context blocks.
+func messageText(msg slack.Message) string {
+	parts := []string{msg.Text}
+	for _, b := range msg.Blocks {
+		if b.Text != nil {
+			parts = append(parts, b.Text.Text)
+		}
+		for _, e := range b.Elements {
+			if text, ok := e.(slack.Text); ok {
+				parts = append(parts, text.Text)
+			}
+		}
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,126 @@
+package signups
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CORS_ALLOWED_ORIGINS are the comma-separated origins browsers may submit signups from, e.g. "https://operationspark.org".
+// An origin can use a wildcard subdomain ("https://*.operationspark.org"), and "*" allows any origin.
+// CORS_ALLOWED_METHODS and CORS_ALLOWED_HEADERS are the methods and request headers they may use.
+var CORS_ALLOWED_ORIGINS = envOr("CORS_ALLOWED_ORIGINS", "https://operationspark.org,https://www.operationspark.org")
+var CORS_ALLOWED_METHODS = envOr("CORS_ALLOWED_METHODS", "POST")
+var CORS_ALLOWED_HEADERS = envOr("CORS_ALLOWED_HEADERS", "Content-Type")
+
+// corsMaxAge is how long (in seconds) browsers may cache a preflight response.
+const corsMaxAge = 3600
+
+// CORSConfig is who can make cross-origin requests, and how.
+type CORSConfig struct {
+	Origins []string
+	Methods []string
+	Headers []string
+}
+
+// corsConfig reads the CORS_ALLOWED_* settings.
+func corsConfig() CORSConfig {
+	return CORSConfig{
+		Origins: splitList(CORS_ALLOWED_ORIGINS),
+		Methods: splitList(CORS_ALLOWED_METHODS),
+		Headers: splitList(CORS_ALLOWED_HEADERS),
+	}
+}
+
+// splitList splits a comma-separated setting, dropping spaces and empty values.
+func splitList(s string) []string {
+	list := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// allowsOrigin reports whether the origin matches an allowed origin.
+func (c CORSConfig) allowsOrigin(origin string) bool {
+	for _, allowed := range c.Origins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+		// "https://*.operationspark.org" matches "https://www.operationspark.org", but not "https://operationspark.org"
+		if i := strings.Index(allowed, "*."); i >= 0 {
+			prefix, suffix := strings.ToLower(allowed[:i]), strings.ToLower(allowed[i+1:])
+			o := strings.ToLower(origin)
+			if strings.HasPrefix(o, prefix) && strings.HasSuffix(o, suffix) && len(o) > len(prefix)+len(suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// allowsMethod reports whether the method is allowed. OPTIONS (preflight) always is.
+func (c CORSConfig) allowsMethod(method string) bool {
+	return method == http.MethodOptions || containsFold(c.Methods, method)
+}
+
+// allowsHeaders reports whether every header in a comma-separated Access-Control-Request-Headers value is allowed.
+func (c CORSConfig) allowsHeaders(requested string) bool {
+	for _, h := range splitList(requested) {
+		if !containsFold(c.Headers, h) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// withCORS handles cross-origin requests from browsers before calling the handler.
+// Preflight (OPTIONS) requests are answered here, and requests from origins that aren't allowed are rejected.
+// Requests without an Origin header (servers, curl) aren't cross-origin browser requests and are passed through.
+func withCORS(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		cfg := corsConfig()
+		if !cfg.allowsOrigin(origin) {
+			http.Error(w, "Origin not allowed", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			if !cfg.allowsMethod(r.Header.Get("Access-Control-Request-Method")) || !cfg.allowsHeaders(r.Header.Get("Access-Control-Request-Headers")) {
+				http.Error(w, "Method or headers not allowed", http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(cfg.Methods, ", "))
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(cfg.Headers, ", "))
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(corsMaxAge))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if !cfg.allowsMethod(r.Method) {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		next(w, r)
+	}
+}
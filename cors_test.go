@@ -0,0 +1,164 @@
+package signups
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAllowsOrigin(t *testing.T) {
+	cfg := CORSConfig{Origins: []string{"https://operationspark.org", "https://*.operationspark.org"}}
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://operationspark.org", true},
+		{"https://OperationSpark.org", true},
+		{"https://www.operationspark.org", true},
+		{"https://apply.staging.operationspark.org", true},
+		{"http://www.operationspark.org", false},
+		{"https://evil-operationspark.org", false},
+		{"https://operationspark.org.example.com", false},
+		{"null", false},
+	}
+	for _, test := range tests {
+		if got := cfg.allowsOrigin(test.origin); got != test.want {
+			t.Errorf("allowsOrigin(%q) = %v, want %v", test.origin, got, test.want)
+		}
+	}
+
+	if !(CORSConfig{Origins: []string{"*"}}).allowsOrigin("https://example.com") {
+		t.Error(`"*" should allow any origin`)
+	}
+}
+
+func TestWithCORS(t *testing.T) {
+	defer setVar(&CORS_ALLOWED_ORIGINS, "https://operationspark.org, https://*.operationspark.org")()
+	defer setVar(&CORS_ALLOWED_METHODS, "POST")()
+	defer setVar(&CORS_ALLOWED_HEADERS, "Content-Type")()
+
+	tests := []struct {
+		name        string
+		method      string
+		headers     map[string]string
+		wantStatus  int
+		wantCalled  bool
+		wantHeaders map[string]string
+	}{
+		{
+			name:        "no origin",
+			method:      http.MethodPost,
+			wantStatus:  http.StatusOK,
+			wantCalled:  true,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:        "allowed origin",
+			method:      http.MethodPost,
+			headers:     map[string]string{"Origin": "https://operationspark.org"},
+			wantStatus:  http.StatusOK,
+			wantCalled:  true,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": "https://operationspark.org", "Vary": "Origin"},
+		},
+		{
+			name:        "wildcard subdomain",
+			method:      http.MethodPost,
+			headers:     map[string]string{"Origin": "https://www.operationspark.org"},
+			wantStatus:  http.StatusOK,
+			wantCalled:  true,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": "https://www.operationspark.org"},
+		},
+		{
+			name:        "disallowed origin",
+			method:      http.MethodPost,
+			headers:     map[string]string{"Origin": "https://example.com"},
+			wantStatus:  http.StatusForbidden,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:       "preflight",
+			method:     http.MethodOptions,
+			headers:    map[string]string{"Origin": "https://operationspark.org", "Access-Control-Request-Method": "POST", "Access-Control-Request-Headers": "content-type"},
+			wantStatus: http.StatusNoContent,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":  "https://operationspark.org",
+				"Access-Control-Allow-Methods": "POST",
+				"Access-Control-Allow-Headers": "Content-Type",
+				"Access-Control-Max-Age":       "3600",
+			},
+		},
+		{
+			name:        "preflight disallowed method",
+			method:      http.MethodOptions,
+			headers:     map[string]string{"Origin": "https://operationspark.org", "Access-Control-Request-Method": "DELETE"},
+			wantStatus:  http.StatusForbidden,
+			wantHeaders: map[string]string{"Access-Control-Allow-Methods": ""},
+		},
+		{
+			name:        "preflight disallowed header",
+			method:      http.MethodOptions,
+			headers:     map[string]string{"Origin": "https://operationspark.org", "Access-Control-Request-Method": "POST", "Access-Control-Request-Headers": "Content-Type, X-Api-Key"},
+			wantStatus:  http.StatusForbidden,
+			wantHeaders: map[string]string{"Access-Control-Allow-Headers": ""},
+		},
+		{
+			name:       "preflight disallowed origin",
+			method:     http.MethodOptions,
+			headers:    map[string]string{"Origin": "https://example.com", "Access-Control-Request-Method": "POST"},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "disallowed method",
+			method:     http.MethodPut,
+			headers:    map[string]string{"Origin": "https://operationspark.org"},
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, test := range tests {
+		called := false
+		handler := withCORS(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			w.WriteHeader(http.StatusOK)
+		})
+		req := httptest.NewRequest(test.method, "/", nil)
+		for k, v := range test.headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		handler(w, req)
+
+		if w.Code != test.wantStatus || called != test.wantCalled {
+			t.Errorf("%s: want %d (handler called: %v), got %d (%v) %s", test.name, test.wantStatus, test.wantCalled, w.Code, called, w.Body)
+		}
+		for k, want := range test.wantHeaders {
+			if got := w.Header().Get(k); got != want {
+				t.Errorf("%s: want %s %q, got %q", test.name, k, want, got)
+			}
+		}
+	}
+}
+
+func TestHandleSignUpPreflight(t *testing.T) {
+	defer setVar(&CORS_ALLOWED_ORIGINS, "https://operationspark.org")()
+	defer setVar(&RATE_LIMIT_IP, "1/1h")()
+	defer setStore(NewMemoryStore())()
+	defer setTrustedProxies(true, "")()
+
+	// Preflight requests are answered before the signup handler, so they don't use up the rate limit
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodOptions, "/", nil)
+		req.Header.Set("Origin", "https://operationspark.org")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+		w := httptest.NewRecorder()
+		HandleSignUp(w, req)
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("preflight %d: want %d, got %d %s", i, http.StatusNoContent, w.Code, w.Body)
+		}
+	}
+	if wait := rateLimited(context.Background(), RATE_LIMIT_IP, "ip:203.0.113.7"); wait != 0 {
+		t.Errorf("preflight requests used the IP rate limit, next request must wait %s", wait)
+	}
+}
@@ -0,0 +1,165 @@
+package signups
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// startDateTimeLayouts are the startDateTime formats the website may send, besides RFC 3339.
+// Times without a UTC offset are in Central time, like the sessions.
+var startDateTimeLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	// JavaScript's Date.toString(), without the time zone name, e.g. "Mon Mar 14 2022 12:00:00 GMT-0500"
+	"Mon Jan 02 2006 15:04:05 GMT-0700",
+}
+
+// InvalidFieldsError is every invalid field in a signup, in the order they were sent.
+type InvalidFieldsError []*InvalidFieldError
+
+func (e InvalidFieldsError) Error() string {
+	msgs := make([]string, len(e))
+	for i, fieldErr := range e {
+		msgs[i] = fieldErr.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// As lets errors.As find the first invalid field, so callers that handle a single InvalidFieldError still work.
+func (e InvalidFieldsError) As(target interface{}) bool {
+	t, ok := target.(**InvalidFieldError)
+	if !ok || len(e) == 0 {
+		return false
+	}
+	*t = e[0]
+	return true
+}
+
+// decodeSignupJSON strictly decodes a JSON object into the Signup. Unlike json.Unmarshal, it rejects unknown fields,
+// and it reports every unknown field, value of the wrong type, and unparsable startDateTime together as an InvalidFieldsError.
+// An InvalidFieldError's Field is the JSON path of the value, e.g. "nameFirst".
+func decodeSignupJSON(s *Signup, b []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		if err != nil && err != io.EOF {
+			return err
+		}
+		return errors.New("signup must be a JSON object")
+	}
+
+	fields := signupJSONFields()
+	v := reflect.ValueOf(s).Elem()
+	var errs InvalidFieldsError
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key := tok.(string)
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return err
+		}
+
+		// The honeypot field is empty in real signups, and isn't a Signup field
+		if HONEYPOT_FIELD != "" && key == HONEYPOT_FIELD {
+			continue
+		}
+		i, ok := fields[strings.ToLower(key)]
+		if !ok {
+			errs = append(errs, &InvalidFieldError{Field: key, Reason: "unknown field"})
+			continue
+		}
+		if err := decodeSignupField(v.Field(i), raw); err != nil {
+			errs = append(errs, &InvalidFieldError{Field: key, Reason: err.Error()})
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("unexpected data after the signup's JSON object")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// decodeSignupField decodes a JSON value into a Signup field.
+func decodeSignupField(field reflect.Value, raw json.RawMessage) error {
+	if field.Type() == reflect.TypeOf(time.Time{}) {
+		t, err := parseStartDateTime(raw)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	err := json.Unmarshal(raw, field.Addr().Interface())
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return fmt.Errorf("want a %s, got %s", typeErr.Type.Kind(), typeErr.Value)
+	}
+	return err
+}
+
+// parseStartDateTime parses a startDateTime in RFC 3339, one of the startDateTimeLayouts, or milliseconds since
+// the Unix epoch (JavaScript's Date.getTime()). null is no start time.
+func parseStartDateTime(raw json.RawMessage) (time.Time, error) {
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return time.Time{}, err
+	}
+
+	switch value := value.(type) {
+	case nil:
+		return time.Time{}, nil
+	case float64:
+		ms, err := strconv.ParseInt(string(raw), 10, 64)
+		if err != nil {
+			return time.Time{}, errors.New("want milliseconds since the Unix epoch")
+		}
+		return time.Unix(0, ms*int64(time.Millisecond)).UTC(), nil
+	case string:
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t, nil
+		}
+		// Drop the time zone name from Date.toString(), e.g. " (Central Daylight Time)"
+		if i := strings.Index(value, " ("); i > 0 {
+			value = value[:i]
+		}
+		for _, layout := range startDateTimeLayouts {
+			if t, err := time.ParseInLocation(layout, value, centralTZ()); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, errors.New("not a date and time")
+	}
+	return time.Time{}, errors.New("want a date and time string")
+}
+
+// signupJSONFields maps the lowercased JSON names of the Signup's fields to their index. Names are matched
+// case-insensitively, like json.Unmarshal does.
+func signupJSONFields() map[string]int {
+	fields := map[string]int{}
+	t := reflect.TypeOf(Signup{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			fields[strings.ToLower(name)] = i
+		}
+	}
+	return fields
+}
@@ -0,0 +1,131 @@
+package signups
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDecodeSignupJSON(t *testing.T) {
+	defer setVar(&HONEYPOT_FIELD, "website")()
+	// errAny is any error that isn't about a field
+	errAny := errors.New("any error")
+	tests := []struct {
+		name    string
+		json    string
+		want    Signup
+		wantErr error
+	}{
+		{
+			name: "valid",
+			json: `{"nameFirst": "Quinta", "NameLast": "Brunson", "startDateTime": "2022-03-14T17:00:00Z"}`,
+			want: Signup{NameFirst: "Quinta", NameLast: "Brunson", StartDateTime: time.Date(2022, 3, 14, 17, 0, 0, 0, time.UTC)},
+		},
+		{
+			name: "honeypot field",
+			json: `{"nameFirst": "Quinta", "website": ""}`,
+			want: Signup{NameFirst: "Quinta"},
+		},
+		{
+			name:    "unknown fields",
+			json:    `{"firstName": "Quinta", "lastName": "Brunson", "email": "quinta@email.com"}`,
+			want:    Signup{Email: "quinta@email.com"},
+			wantErr: InvalidFieldsError{{Field: "firstName", Reason: "unknown field"}, {Field: "lastName", Reason: "unknown field"}},
+		},
+		{
+			name: "type mismatches and bad date",
+			json: `{"nameFirst": "Quinta", "cell": 5551234567, "email": ["quinta@email.com"], "startDateTime": "next Monday"}`,
+			want: Signup{NameFirst: "Quinta"},
+			wantErr: InvalidFieldsError{
+				{Field: "cell", Reason: "want a string, got number"},
+				{Field: "email", Reason: "want a string, got array"},
+				{Field: "startDateTime", Reason: "not a date and time"},
+			},
+		},
+		{
+			name:    "date of the wrong type",
+			json:    `{"startDateTime": true}`,
+			wantErr: InvalidFieldsError{{Field: "startDateTime", Reason: "want a date and time string"}},
+		},
+		{name: "not an object", json: `["Quinta"]`, wantErr: errAny},
+		{name: "trailing data", json: `{"nameFirst": "Quinta"} {}`, want: Signup{NameFirst: "Quinta"}, wantErr: errAny},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Signup{}
+			err := decodeSignupJSON(&got, []byte(test.json))
+			if (err != nil) != (test.wantErr != nil) {
+				t.Fatalf("want error %v, got %v", test.wantErr, err)
+			}
+			if fieldsErr, ok := test.wantErr.(InvalidFieldsError); ok {
+				if diff := cmp.Diff(fieldsErr, err); diff != "" {
+					t.Errorf("error mismatch (-want +got):\n%s", diff)
+				}
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Signup mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseStartDateTime(t *testing.T) {
+	ctz := centralTZ()
+	want := time.Date(2022, 3, 14, 12, 0, 0, 0, ctz)
+	tests := []struct {
+		json    string
+		want    time.Time
+		wantErr bool
+	}{
+		{`"2022-03-14T17:00:00Z"`, want, false},
+		{`"2022-03-14T17:00:00.000Z"`, want, false},
+		{`"2022-03-14T12:00:00-05:00"`, want, false},
+		{`"2022-03-14T12:00:00"`, want, false},
+		{`"2022-03-14T12:00"`, want, false},
+		{`"2022-03-14 12:00"`, want, false},
+		{`"Mon Mar 14 2022 12:00:00 GMT-0500 (Central Daylight Time)"`, want, false},
+		{`1647277200000`, want, false},
+		{`null`, time.Time{}, false},
+		{`""`, time.Time{}, true},
+		{`"3/14/2022"`, time.Time{}, true},
+		{`1647277200000.5`, time.Time{}, true},
+	}
+	for _, test := range tests {
+		got, err := parseStartDateTime(json.RawMessage(test.json))
+		if (err != nil) != test.wantErr || !got.Equal(test.want) {
+			t.Errorf("parseStartDateTime(%s) = %s, %v; want %s (error: %v)", test.json, got, err, test.want, test.wantErr)
+		}
+	}
+}
+
+func TestHandleSignUpFieldErrors(t *testing.T) {
+	defer setStore(NewMemoryStore())()
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"firstName": "Quinta", "lastName": "Brunson", "email": "quinta@email.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	HandleSignUp(w, req)
+
+	var got SignupResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("want JSON response, got %q", w.Body)
+	}
+	want := SignupResponse{
+		Error:  "invalid value for field: 'firstName' (unknown field); invalid value for field: 'lastName' (unknown field)",
+		Field:  "firstName",
+		Errors: InvalidFieldsError{{Field: "firstName", Reason: "unknown field"}, {Field: "lastName", Reason: "unknown field"}},
+	}
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("want status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("response mismatch (-want +got):\n%s", diff)
+	}
+}
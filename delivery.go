@@ -0,0 +1,94 @@
+package signups
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// DOWNSTREAM_TIMEOUT limits how long delivery to each target (Greenlight, Slack, email) can take, as a Go duration. It defaults to 10s.
+var DOWNSTREAM_TIMEOUT = os.Getenv("DOWNSTREAM_TIMEOUT")
+
+// downstreamTimeout parses DOWNSTREAM_TIMEOUT, falling back to 10 seconds.
+func downstreamTimeout() time.Duration {
+	if DOWNSTREAM_TIMEOUT == "" {
+		return 10 * time.Second
+	}
+	d, err := time.ParseDuration(DOWNSTREAM_TIMEOUT)
+	if err != nil || d <= 0 {
+		fmt.Printf("ignoring invalid DOWNSTREAM_TIMEOUT %q\n", DOWNSTREAM_TIMEOUT)
+		return 10 * time.Second
+	}
+	return d
+}
+
+// deliver sends the signup to the targets, or all targets if none are given, recording each result on the record.
+// Targets are delivered in order: Greenlight, Slack (#signups and routed channels), then the welcome email.
+// A Greenlight or Slack failure stops delivery and is returned. Email failures are recorded and logged.
+// Slack destinations the signup was already posted to are skipped, so a retry only posts where the last attempt failed.
+// The welcome email is suppressed for repeat signups already emailed within DUPLICATE_EMAIL_WINDOW. It's still sent
+// when the address's domain looks like it can't receive email, since the check can be wrong; staff see a warning instead. The emailKind (emailConfirmation or emailFollowUp)
+// decides whether it's sent to an address on the suppression list.
+func deliver(ctx context.Context, rec *Record, emailKind string, targets ...string) error {
+	s := &rec.Signup
+	want := func(target string) bool {
+		return len(targets) == 0 || contains(targets, target)
+	}
+	timeout := downstreamTimeout()
+
+	// Post to Greenlight
+	if want(TargetGreenlight) {
+		tctx, cancel := context.WithTimeout(ctx, timeout)
+		err := s.SignUp(tctx, rec.History)
+		cancel()
+		rec.record(TargetGreenlight, err)
+		if err != nil {
+			return err
+		}
+	}
+
+	// #signups (and routed channels) Slack notification, skipping destinations it was already posted to
+	if want(TargetSlack) {
+		router := s.slackRouter()
+		dests := []string{}
+		for _, dest := range router.Destinations(s.slackAttributes()) {
+			if !rec.postedToSlack(dest) {
+				dests = append(dests, dest)
+			}
+		}
+		tctx, cancel := context.WithTimeout(ctx, timeout)
+		refs, sent, err := router.SendTo(tctx, dests, signupMessage(rec))
+		cancel()
+		rec.SlackMessages = append(rec.SlackMessages, refs...)
+		rec.recordSlack(sent, err)
+		if err != nil {
+			return fmt.Errorf("error sending Slack webhook: %w", err)
+		}
+	}
+
+	//  Send the program's welcome email, unless the person was just sent one
+	if want(TargetEmail) {
+		suppress, err := suppressWelcome(rec)
+		if err != nil {
+			fmt.Printf("error checking for duplicate welcome email %s\n", err.Error())
+		}
+		if suppress {
+			rec.Deliveries = append(rec.Deliveries, Delivery{Target: TargetEmail, Status: StatusSuppressed, At: time.Now().UTC()})
+			return nil
+		}
+		tctx, cancel := context.WithTimeout(ctx, timeout)
+		id, err := s.sendWelcome(tctx, emailKind)
+		cancel()
+		if errors.Is(err, ErrSuppressed) {
+			rec.Deliveries = append(rec.Deliveries, Delivery{Target: TargetEmail, Status: StatusSuppressed, Error: err.Error(), At: time.Now().UTC()})
+			return nil
+		}
+		rec.recordEmail(id, err)
+		if err != nil {
+			fmt.Printf("error sending welcome email %s", err.Error())
+		}
+	}
+	return nil
+}
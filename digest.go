@@ -0,0 +1,188 @@
+package signups
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/operationspark/slack-session-signups/slack"
+)
+
+// DIGEST_SLACK_DESTINATION is the Incoming Webhook URL or channel ID the digest is posted to. It defaults to the #signups webhook.
+var DIGEST_SLACK_DESTINATION = envOr("DIGEST_SLACK_DESTINATION", SLACK_WEBHOOK_URL)
+
+// Digest summarizes the signups received during a period.
+type Digest struct {
+	Period string
+	Since  time.Time
+	Until  time.Time
+	Total  int
+	// ByCohort and ByChannel count the period's signups by session and referral channel.
+	ByCohort  map[string]int
+	ByChannel map[string]int
+	// NoSession are the signups that requested information instead of picking a session time.
+	NoSession []*Record
+	// Failures are the signups that could not be delivered to at least one target.
+	Failures []*Record
+	// Undeliverable are the signups whose welcome email was sent, but bounced or was marked as spam.
+	Undeliverable []*Record
+}
+
+// digestPeriod returns the time range a digest covers: the previous day ("daily") or the previous 7 days ("weekly"), ending at midnight Central time.
+func digestPeriod(period string, now time.Time) (since, until time.Time, err error) {
+	y, m, d := now.In(centralTZ()).Date()
+	until = time.Date(y, m, d, 0, 0, 0, 0, centralTZ())
+	switch period {
+	case "daily":
+		return until.AddDate(0, 0, -1), until, nil
+	case "weekly":
+		return until.AddDate(0, 0, -7), until, nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("unknown digest period: '%s'. Use 'daily' or 'weekly'", period)
+}
+
+// buildDigest summarizes the signups in the store for the period ending before now.
+func buildDigest(ctx context.Context, period string, now time.Time) (Digest, error) {
+	since, until, err := digestPeriod(period, now)
+	if err != nil {
+		return Digest{}, err
+	}
+	records, err := signupStore.Find(ctx, Query{Since: since, Until: until})
+	if err != nil {
+		return Digest{}, err
+	}
+
+	d := Digest{
+		Period:    period,
+		Since:     since,
+		Until:     until,
+		Total:     len(records),
+		ByCohort:  map[string]int{},
+		ByChannel: map[string]int{},
+	}
+	for _, rec := range records {
+		s := rec.Signup
+		if s.StartDateTime.IsZero() {
+			d.NoSession = append(d.NoSession, rec)
+		} else {
+			d.ByCohort[s.Cohort]++
+		}
+		d.ByChannel[s.Attribution().Channel]++
+		if len(rec.Failed()) > 0 {
+			d.Failures = append(d.Failures, rec)
+		}
+		switch rec.Status(TargetEmail) {
+		case StatusBounced, StatusComplained:
+			d.Undeliverable = append(d.Undeliverable, rec)
+		}
+	}
+	return d, nil
+}
+
+// Message formats the digest for Slack.
+func (d Digest) Message() slack.Message {
+	title := fmt.Sprintf("%s%s signup digest: %s", strings.ToUpper(d.Period[:1]), d.Period[1:], countOf(d.Total, "signup"))
+	dates := d.Since.Format("Mon Jan 2")
+	if d.Until.Sub(d.Since) > 24*time.Hour {
+		dates += " – " + d.Until.AddDate(0, 0, -1).Format("Mon Jan 2")
+	}
+
+	blocks := []slack.Block{
+		slack.Section(fmt.Sprintf("*%s*", title)),
+		slack.Context(dates),
+	}
+	if d.Total == 0 {
+		return slack.Message{Text: title, Blocks: blocks}
+	}
+
+	if len(d.ByCohort) > 0 {
+		blocks = append(blocks, slack.Section("*By session*\n"+countLines(d.ByCohort)))
+	}
+	blocks = append(blocks, slack.Section("*By referral channel*\n"+countLines(d.ByChannel)))
+
+	if len(d.NoSession) > 0 {
+		blocks = append(blocks, slack.Section(fmt.Sprintf("*Requested information (no session selected): %d*\n%s", len(d.NoSession), digestLines(d.NoSession, nil))))
+	}
+	if len(d.Failures) > 0 {
+		failed := func(rec *Record) string {
+			return "failed: " + strings.Join(rec.Failed(), ", ")
+		}
+		blocks = append(blocks, slack.Section(fmt.Sprintf("*Delivery failures: %d*\n%s", len(d.Failures), digestLines(d.Failures, failed))))
+	}
+	if len(d.Undeliverable) > 0 {
+		emailStatus := func(rec *Record) string {
+			return rec.Status(TargetEmail)
+		}
+		blocks = append(blocks, slack.Section(fmt.Sprintf("*Welcome emails bounced or marked as spam: %d*\n%s", len(d.Undeliverable), digestLines(d.Undeliverable, emailStatus))))
+	}
+	return slack.Message{Text: title, Blocks: blocks}
+}
+
+// digestLines lists the signups, with an optional note for each.
+func digestLines(records []*Record, note func(*Record) string) string {
+	lines := []string{}
+	for _, rec := range records {
+		if len(lines) == maxListed {
+			lines = append(lines, fmt.Sprintf("_…and %d more_", len(records)-maxListed))
+			break
+		}
+		line := fmt.Sprintf("• %s %s (%s)", rec.Signup.NameFirst, rec.Signup.NameLast, rec.Signup.Email)
+		if note != nil {
+			line += " — " + note(rec)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// RunDigest summarizes the period's signups and posts the digest to Slack, unless dryRun is set.
+// Period is "daily" (yesterday) or "weekly" (the last 7 days).
+func RunDigest(ctx context.Context, period string, now time.Time, dryRun bool) (slack.Message, error) {
+	d, err := buildDigest(ctx, period, now)
+	if err != nil {
+		return slack.Message{}, err
+	}
+	msg := d.Message()
+	if dryRun {
+		return msg, nil
+	}
+
+	router := slack.Router{Default: []string{DIGEST_SLACK_DESTINATION}, Client: slackClient()}
+	_, err = router.Send(ctx, slack.Attributes{}, msg)
+	return msg, err
+}
+
+// HandleDigest posts a signup digest to Slack. It is triggered by Cloud Scheduler and requires the ADMIN_TOKEN.
+// The "period" query parameter is "daily" (default) or "weekly". With "dryRun=true", the digest is returned instead of posted.
+func HandleDigest(w http.ResponseWriter, r *http.Request) {
+	if !authorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = "daily"
+	}
+	if _, _, err := digestPeriod(period, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dryRun := r.URL.Query().Get("dryRun") == "true"
+
+	msg, err := RunDigest(r.Context(), period, time.Now(), dryRun)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		fmt.Printf("error running %s digest %s\n", period, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(msg)
+	if err != nil {
+		fmt.Printf("error writing digest response %s\n", err.Error())
+	}
+}
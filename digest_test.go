@@ -0,0 +1,124 @@
+package signups
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/operationspark/slack-session-signups/slack/slacktest"
+)
+
+func TestDigest(t *testing.T) {
+	defer setStore(NewMemoryStore())()
+	// 8:00 AM Central on Thursday, Mar 10
+	now, _ := time.Parse(time.RFC3339, "2022-03-10T14:00:00Z")
+	sessionStart, _ := time.Parse(time.RFC3339, "2022-03-14T17:00:00Z")
+
+	seed := []struct {
+		signup     Signup
+		receivedAt time.Time
+		failed     string
+		// emailStatus is the welcome email's status after it was sent, e.g. from a Mailgun bounce event
+		emailStatus string
+	}{
+		{Signup{NameFirst: "Henri", NameLast: "Testaroni", Email: "henri@email.com", Referrer: "instagram", Cohort: "is-mar-14-22-12pm", StartDateTime: sessionStart}, now.Add(-12 * time.Hour), "", ""},
+		{Signup{NameFirst: "Yasiin", NameLast: "Bey", Email: "yasiin@email.com", Referrer: "instagram", Cohort: "is-mar-14-22-12pm", StartDateTime: sessionStart}, now.Add(-20 * time.Hour), TargetEmail, ""},
+		{Signup{NameFirst: "Quinta", NameLast: "Brunson", Email: "quinta@email.com", Cohort: "is-mar-14-22-12pm", StartDateTime: sessionStart}, now.Add(-18 * time.Hour), "", StatusBounced},
+		{Signup{NameFirst: "Solána", NameLast: "Rowe", Email: "solana@email.com"}, now.Add(-3 * 24 * time.Hour), "", ""},
+		// Today's signups are in tomorrow's digest
+		{Signup{NameFirst: "Amir", NameLast: "Thompson", Email: "amir@email.com"}, now.Add(-1 * time.Hour), "", ""},
+	}
+	for _, s := range seed {
+		rec := newRecord(s.signup)
+		rec.ReceivedAt = s.receivedAt
+		rec.record(TargetGreenlight, nil)
+		if s.failed != "" {
+			rec.record(s.failed, errors.New("boom"))
+		}
+		if s.emailStatus != "" {
+			rec.recordEmail("20220309170000.1@mail.operationspark.org", nil)
+			rec.Deliveries[len(rec.Deliveries)-1].Status = s.emailStatus
+		}
+		if err := signupStore.Save(context.Background(), rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		period  string
+		want    []string
+		notWant []string
+	}{
+		{
+			period: "daily",
+			want: []string{"Daily signup digest: 3 signups", "Wed Mar 9", "• is-mar-14-22-12pm: 3", "• instagram: 2",
+				"Delivery failures: 1*\n• Yasiin Bey (yasiin@email.com) — failed: email",
+				"Welcome emails bounced or marked as spam: 1*\n• Quinta Brunson (quinta@email.com) — bounced"},
+			notWant: []string{"Solána", "Amir", "Requested information"},
+		},
+		{
+			period:  "weekly",
+			want:    []string{"Weekly signup digest: 4 signups", "Thu Mar 3 – Wed Mar 9", "• not given: 2", "Requested information (no session selected): 1*\n• Solána Rowe (solana@email.com)"},
+			notWant: []string{"Amir"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.period, func(t *testing.T) {
+			msg, err := RunDigest(context.Background(), test.period, now, true)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			got := messageText(msg)
+			for _, want := range test.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("digest missing %q\n%s", want, got)
+				}
+			}
+			for _, notWant := range test.notWant {
+				if strings.Contains(got, notWant) {
+					t.Errorf("digest should not contain %q\n%s", notWant, got)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleDigest(t *testing.T) {
+	srv := slacktest.NewServer()
+	defer srv.Close()
+	defer setVar(&ADMIN_TOKEN, "admin-secret")()
+	defer setVar(&SLACK_BOT_TOKEN, "")()
+	defer setVar(&DIGEST_SLACK_DESTINATION, srv.WebhookURL("leadership"))()
+	defer setStore(NewMemoryStore())()
+
+	tests := []struct {
+		name       string
+		token      string
+		query      string
+		wantStatus int
+		wantPosts  int
+	}{
+		{"missing token", "", "", http.StatusUnauthorized, 0},
+		{"unknown period", "admin-secret", "?period=monthly", http.StatusBadRequest, 0},
+		{"dry run", "admin-secret", "?period=weekly&dryRun=true", http.StatusOK, 0},
+		{"daily", "admin-secret", "", http.StatusOK, 1},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/digest"+test.query, nil)
+		req.Header.Set("Authorization", "Bearer "+test.token)
+		w := httptest.NewRecorder()
+		HandleDigest(w, req)
+		if w.Code != test.wantStatus {
+			t.Errorf("%s: want status %d, got %d", test.name, test.wantStatus, w.Code)
+		}
+		if got := len(srv.Calls()); got != test.wantPosts {
+			t.Errorf("%s: want %d Slack posts, got %d", test.name, test.wantPosts, got)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package signups
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// DUPLICATE_EMAIL_WINDOW suppresses the welcome email for a repeat signup if a previous signup from the same person was emailed within the window, e.g. "72h".
+// Welcome emails are never suppressed when it is empty.
+var DUPLICATE_EMAIL_WINDOW = os.Getenv("DUPLICATE_EMAIL_WINDOW")
+
+// PriorSignup is an earlier signup from the same person.
+type PriorSignup struct {
+	Id         string    `json:"id"`
+	Cohort     string    `json:"cohort"`
+	SessionId  string    `json:"sessionId"`
+	ReceivedAt time.Time `json:"receivedAt"`
+	// Attended is "yes" or "no" once Greenlight reports it (see HandleSignupEvent), otherwise "unknown".
+	Attended string `json:"attended"`
+	// Emailed is true if the welcome email was delivered for the signup.
+	Emailed bool `json:"emailed"`
+	// SameEmail is true if the signup has the same normalized email address, not just the same phone number.
+	// Family members can share a phone number, so only these signups suppress the welcome email.
+	SameEmail bool `json:"sameEmail"`
+}
+
+// Applicant identifies a person by their normalized email address or phone number.
+type Applicant struct {
+	Email string
+	Phone string
+}
+
+// applicant returns the Signup's normalized contact info.
+func (s *Signup) applicant() Applicant {
+	return Applicant{Email: normalizeEmail(s.Email), Phone: normalizePhone(s.Cell)}
+}
+
+// Matches reports whether the signup is from the same person, by email or phone.
+func (a Applicant) Matches(s *Signup) bool {
+	other := s.applicant()
+	return (a.Email != "" && a.Email == other.Email) || (a.Phone != "" && a.Phone == other.Phone)
+}
+
+// normalizeEmail lowercases the address and removes "+tags". Gmail addresses also have dots removed, since Gmail ignores them.
+func normalizeEmail(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	at := strings.LastIndex(email, "@")
+	if at < 1 {
+		return email
+	}
+	local, domain := email[:at], email[at+1:]
+	if plus := strings.Index(local, "+"); plus > 0 {
+		local = local[:plus]
+	}
+	if domain == "gmail.com" || domain == "googlemail.com" {
+		local = strings.ReplaceAll(local, ".", "")
+		domain = "gmail.com"
+	}
+	return local + "@" + domain
+}
+
+// normalizePhone keeps the digits of a US phone number, without the country code.
+func normalizePhone(phone string) string {
+	var b strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	digits := b.String()
+	if len(digits) == 11 && digits[0] == '1' {
+		return digits[1:]
+	}
+	return digits
+}
+
+// findHistory returns the person's signups received before the record, oldest first.
+func findHistory(ctx context.Context, rec *Record) ([]PriorSignup, error) {
+	a := rec.Signup.applicant()
+	if a.Email == "" && a.Phone == "" {
+		return nil, nil
+	}
+	records, err := signupStore.Find(ctx, Query{Applicant: a, Until: rec.ReceivedAt})
+	if err != nil {
+		return nil, err
+	}
+
+	history := []PriorSignup{}
+	for _, r := range records {
+		if r.Id == rec.Id {
+			continue
+		}
+		history = append(history, PriorSignup{
+			Id:         r.Id,
+			Cohort:     r.Signup.Cohort,
+			SessionId:  r.Signup.SessionId,
+			ReceivedAt: r.ReceivedAt,
+			Attended:   attended(r),
+			Emailed:    r.Status(TargetEmail) == StatusDelivered || r.Status(TargetEmail) == StatusComplained,
+			SameEmail:  a.Email != "" && r.Signup.applicant().Email == a.Email,
+		})
+	}
+	return history, nil
+}
+
+// attended reports whether the person attended the signup's session, from Greenlight's follow-up events.
+func attended(r *Record) string {
+	result := "unknown"
+	for _, a := range r.Activity {
+		switch a.Action {
+		case "attended":
+			result = "yes"
+		case "no-show", "did not attend":
+			result = "no"
+		}
+	}
+	return result
+}
+
+// repeatNote describes a repeat signup for Slack, e.g. "3rd signup, previously is-feb-28-22-12pm, attended: unknown".
+// It is empty for first-time signups.
+func repeatNote(history []PriorSignup) string {
+	if len(history) == 0 {
+		return ""
+	}
+	sessions := []string{}
+	result := "unknown"
+	for _, p := range history {
+		session := p.Cohort
+		if session == "" {
+			session = "no session selected"
+		}
+		sessions = append(sessions, session)
+		if p.Attended != "unknown" {
+			result = p.Attended
+		}
+	}
+	return fmt.Sprintf("%s signup, previously %s, attended: %s", ordinal(len(history)+1), strings.Join(sessions, ", "), result)
+}
+
+// suppressWelcome reports whether the welcome email should be skipped because the same email address was already emailed within DUPLICATE_EMAIL_WINDOW.
+func suppressWelcome(rec *Record) (bool, error) {
+	if DUPLICATE_EMAIL_WINDOW == "" {
+		return false, nil
+	}
+	window, err := time.ParseDuration(DUPLICATE_EMAIL_WINDOW)
+	if err != nil {
+		return false, fmt.Errorf("invalid DUPLICATE_EMAIL_WINDOW: %w", err)
+	}
+	for _, p := range rec.History {
+		if p.Emailed && p.SameEmail && rec.ReceivedAt.Sub(p.ReceivedAt) < window {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ordinal formats n as "1st", "2nd", "3rd", "4th", "11th", etc.
+func ordinal(n int) string {
+	suffix := "th"
+	switch n % 10 {
+	case 1:
+		suffix = "st"
+	case 2:
+		suffix = "nd"
+	case 3:
+		suffix = "rd"
+	}
+	if n%100 >= 11 && n%100 <= 13 {
+		suffix = "th"
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
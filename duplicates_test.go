@@ -0,0 +1,167 @@
+package signups
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/operationspark/slack-session-signups/slack/slacktest"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		email string
+		want  string
+	}{
+		{"henri@email.com", "henri@email.com"},
+		{"  Henri@Email.COM ", "henri@email.com"},
+		{"henri+signups@email.com", "henri@email.com"},
+		{"h.testaroni@email.com", "h.testaroni@email.com"},
+		{"H.Testaroni+os@gmail.com", "htestaroni@gmail.com"},
+		{"h.testaroni@googlemail.com", "htestaroni@gmail.com"},
+		{"not an email", "not an email"},
+	}
+	for _, test := range tests {
+		if got := normalizeEmail(test.email); got != test.want {
+			t.Errorf("normalizeEmail(%q) = %q, want %q", test.email, got, test.want)
+		}
+	}
+}
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		phone string
+		want  string
+	}{
+		{"504-555-0100", "5045550100"},
+		{"(504) 555-0100", "5045550100"},
+		{"+1 504.555.0100", "5045550100"},
+		{"15045550100", "5045550100"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if got := normalizePhone(test.phone); got != test.want {
+			t.Errorf("normalizePhone(%q) = %q, want %q", test.phone, got, test.want)
+		}
+	}
+}
+
+func TestFindHistory(t *testing.T) {
+	defer setStore(NewMemoryStore())()
+	ctx := context.Background()
+	received, _ := time.Parse(time.RFC3339, "2022-03-10T18:00:00Z")
+
+	first := &Record{Id: "first", Signup: Signup{Email: "henri@email.com", Cohort: "is-feb-28-22-12pm"}, ReceivedAt: received.Add(-240 * time.Hour)}
+	first.Activity = []Activity{{Action: "no-show"}}
+	// A different email address, but the same phone number
+	second := &Record{Id: "second", Signup: Signup{Email: "henri.t@email.com", Cell: "504-555-0100", Cohort: "is-mar-07-22-12pm"}, ReceivedAt: received.Add(-72 * time.Hour)}
+	second.record(TargetEmail, nil)
+	other := &Record{Id: "other", Signup: Signup{Email: "solana@email.com"}, ReceivedAt: received.Add(-time.Hour)}
+	for _, r := range []*Record{first, second, other} {
+		if err := signupStore.Save(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := &Record{Id: "third", Signup: Signup{Email: "Henri+OS@email.com", Cell: "(504) 555-0100", Cohort: "is-mar-14-22-12pm"}, ReceivedAt: received}
+	got, err := findHistory(ctx, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []PriorSignup{
+		{Id: "first", Cohort: "is-feb-28-22-12pm", ReceivedAt: first.ReceivedAt, Attended: "no", SameEmail: true},
+		{Id: "second", Cohort: "is-mar-07-22-12pm", ReceivedAt: second.ReceivedAt, Attended: "unknown", Emailed: true},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("findHistory() mismatch (-want +got):\n%s", diff)
+	}
+
+	wantNote := "3rd signup, previously is-feb-28-22-12pm, is-mar-07-22-12pm, attended: no"
+	if note := repeatNote(got); note != wantNote {
+		t.Errorf("repeatNote() = %q, want %q", note, wantNote)
+	}
+}
+
+func TestSuppressWelcome(t *testing.T) {
+	received, _ := time.Parse(time.RFC3339, "2022-03-10T18:00:00Z")
+	rec := &Record{
+		ReceivedAt: received,
+		History: []PriorSignup{
+			{Id: "emailed", ReceivedAt: received.Add(-72 * time.Hour), Emailed: true, SameEmail: true},
+			{Id: "not-emailed", ReceivedAt: received.Add(-time.Hour), SameEmail: true},
+			// Someone else with the same phone number
+			{Id: "same-phone", ReceivedAt: received.Add(-2 * time.Hour), Emailed: true},
+		},
+	}
+	tests := []struct {
+		window string
+		want   bool
+	}{
+		{"", false},
+		{"24h", false},
+		{"48h", false},
+		{"96h", true},
+	}
+	for _, test := range tests {
+		defer setVar(&DUPLICATE_EMAIL_WINDOW, test.window)()
+		got, err := suppressWelcome(rec)
+		if err != nil {
+			t.Fatalf("window %q: %s", test.window, err)
+		}
+		if got != test.want {
+			t.Errorf("window %q: want suppress %t, got %t", test.window, test.want, got)
+		}
+	}
+
+	// Suppressed emails are recorded, and not sent
+	defer setVar(&DUPLICATE_EMAIL_WINDOW, "96h")()
+	if err := deliver(context.Background(), rec, emailConfirmation, TargetEmail); err != nil {
+		t.Fatal(err)
+	}
+	if status := rec.Status(TargetEmail); status != StatusSuppressed {
+		t.Errorf("want email %s, got %s", StatusSuppressed, status)
+	}
+}
+
+func TestDeliverRepeatSignup(t *testing.T) {
+	var greenlightBody []byte
+	greenlight := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		greenlightBody, _ = io.ReadAll(r.Body)
+	}))
+	defer greenlight.Close()
+	slackSrv := slacktest.NewServer()
+	defer slackSrv.Close()
+
+	t.Setenv("GREENLIGHT_WEBHOOK_URL", greenlight.URL)
+	defer setVar(&SLACK_WEBHOOK_URL, slackSrv.WebhookURL("signups"))()
+	defer setVar(&SLACK_BOT_TOKEN, "")()
+
+	received, _ := time.Parse(time.RFC3339, "2022-03-10T18:00:00Z")
+	rec := newRecord(Signup{NameFirst: "Henri", Email: "henri@email.com"})
+	rec.History = []PriorSignup{{Id: "first", Cohort: "is-feb-28-22-12pm", ReceivedAt: received, Attended: "unknown"}}
+	if err := deliver(context.Background(), rec, emailConfirmation, TargetGreenlight, TargetSlack); err != nil {
+		t.Fatal(err)
+	}
+
+	var body struct {
+		Email           string        `json:"email"`
+		PreviousSignups []PriorSignup `json:"previousSignups"`
+	}
+	if err := json.Unmarshal(greenlightBody, &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Email != "henri@email.com" || len(body.PreviousSignups) != 1 || body.PreviousSignups[0].Id != "first" {
+		t.Errorf("want Greenlight body with the signup and its history, got %s", greenlightBody)
+	}
+
+	calls := slackSrv.Calls()
+	if len(calls) != 1 || !strings.Contains(string(calls[0].Body), "2nd signup, previously is-feb-28-22-12pm, attended: unknown") {
+		t.Errorf("want the Slack message annotated with the signup history, got %v", calls)
+	}
+}
@@ -0,0 +1,200 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// ErrInvalidAddress is returned by CheckSyntax for a value that isn't a plain email address.
+var ErrInvalidAddress = errors.New("not an email address")
+
+// Resolver looks up a domain's mail servers. *net.Resolver is a Resolver.
+type Resolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+}
+
+// commonDomains are the email domains most applicants use. Domains a typo away from them get a suggestion.
+var commonDomains = []string{
+	"gmail.com", "yahoo.com", "hotmail.com", "outlook.com", "icloud.com", "aol.com", "live.com", "msn.com",
+	"me.com", "mail.com", "email.com", "ymail.com", "protonmail.com", "comcast.net", "att.net", "bellsouth.net", "cox.net",
+	"sbcglobal.net", "charter.net", "verizon.net",
+}
+
+// disposableDomains are throwaway inbox services. Mail to them is usually never read.
+var disposableDomains = map[string]bool{
+	"10minutemail.com":  true,
+	"burnermail.io":     true,
+	"dispostable.com":   true,
+	"emailondeck.com":   true,
+	"fakeinbox.com":     true,
+	"getnada.com":       true,
+	"guerrillamail.com": true,
+	"maildrop.cc":       true,
+	"mailinator.com":    true,
+	"mailnesia.com":     true,
+	"mintemail.com":     true,
+	"moakt.com":         true,
+	"sharklasers.com":   true,
+	"spamgourmet.com":   true,
+	"temp-mail.org":     true,
+	"tempmail.com":      true,
+	"throwawaymail.com": true,
+	"trashmail.com":     true,
+	"yopmail.com":       true,
+}
+
+// Check is what a Checker found out about an email address. The zero Check found nothing wrong.
+type Check struct {
+	// Suggestion is the address with a commonly mistyped domain corrected, e.g. "henri@gmail.com" for "henri@gmial.com".
+	Suggestion string `json:"suggestion,omitempty"`
+	// Disposable means the domain is a throwaway inbox service.
+	Disposable bool `json:"disposable,omitempty"`
+	// NoMailServer means the domain doesn't exist or doesn't accept email, so email to it would bounce.
+	NoMailServer bool `json:"noMailServer,omitempty"`
+}
+
+// Warnings describe the problems with the address, for staff.
+func (c Check) Warnings() []string {
+	warnings := []string{}
+	if c.Suggestion != "" {
+		warnings = append(warnings, fmt.Sprintf("Email may be mistyped. Did they mean %s?", c.Suggestion))
+	}
+	if c.NoMailServer {
+		warnings = append(warnings, "Email domain doesn't accept email")
+	}
+	if c.Disposable {
+		warnings = append(warnings, "Disposable email address")
+	}
+	return warnings
+}
+
+// Checker checks email addresses for typos and domains that can't (or won't) receive email.
+type Checker struct {
+	// Resolver looks up mail servers. Without one, mail servers aren't checked.
+	Resolver Resolver
+	// Timeout limits how long looking up mail servers can take. Lookups that time out are not reported.
+	Timeout time.Duration
+}
+
+// NewChecker creates a Checker that looks up mail servers with the resolver.
+func NewChecker(r Resolver) *Checker {
+	return &Checker{Resolver: r, Timeout: 2 * time.Second}
+}
+
+// CheckSyntax checks that the address is a plain email address, e.g. "henri@email.com" but not "Henri <henri@email.com>".
+func CheckSyntax(address string) error {
+	addr, err := mail.ParseAddress(address)
+	if err != nil || addr.Address != strings.TrimSpace(address) {
+		return ErrInvalidAddress
+	}
+	return nil
+}
+
+// Check checks the address's syntax, then its domain. Only invalid syntax is an error;
+// the domain's problems are reported in the Check, since the address may still work.
+func (c *Checker) Check(ctx context.Context, address string) (Check, error) {
+	if err := CheckSyntax(address); err != nil {
+		return Check{}, err
+	}
+	address = strings.TrimSpace(address)
+	at := strings.LastIndex(address, "@")
+	local, domain := address[:at], strings.ToLower(address[at+1:])
+
+	check := Check{
+		Disposable:   disposableDomains[domain],
+		NoMailServer: c.noMailServer(ctx, domain),
+	}
+	// Allow a second typo when the domain can't receive email, but not for real domains like "outlook.es"
+	maxTypos := 1
+	if check.NoMailServer && len(domain) > 7 {
+		maxTypos = 2
+	}
+	if suggestion := suggestDomain(domain, maxTypos); suggestion != "" {
+		check.Suggestion = local + "@" + suggestion
+	}
+	return check, nil
+}
+
+// noMailServer reports whether DNS says the domain has no mail servers.
+// Lookup failures other than a missing domain (timeouts, unreachable DNS servers) aren't reported.
+func (c *Checker) noMailServer(ctx context.Context, domain string) bool {
+	if c.Resolver == nil {
+		return false
+	}
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	mx, err := c.Resolver.LookupMX(ctx, domain)
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return true
+	}
+	if err != nil {
+		fmt.Printf("error looking up mail servers for %s %s\n", domain, err.Error())
+		return false
+	}
+	// A "null MX" (RFC 7505) says the domain doesn't accept email
+	return len(mx) == 0 || (len(mx) == 1 && mx[0].Host == ".")
+}
+
+// suggestDomain returns the common domain within maxTypos edits of the domain, or "" if there isn't one.
+func suggestDomain(domain string, maxTypos int) string {
+	best, bestDistance := "", 0
+	for _, d := range commonDomains {
+		if d == domain {
+			return ""
+		}
+		dist := editDistance(domain, d)
+		if best == "" || dist < bestDistance {
+			best, bestDistance = d, dist
+		}
+	}
+	if bestDistance > maxTypos {
+		return ""
+	}
+	return best
+}
+
+// editDistance counts the insertions, deletions, substitutions, and swaps of adjacent letters that turn a into b
+// (the optimal string alignment distance), so "gmial.com" is one edit from "gmail.com".
+func editDistance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+func min(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,66 @@
+package email
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeResolver answers MX lookups from a map. Domains that aren't in it don't exist.
+type fakeResolver map[string][]*net.MX
+
+func (f fakeResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	if name == "unreachable.com" {
+		return nil, &net.DNSError{Err: "server misbehaving", Name: name, IsTemporary: true}
+	}
+	mx, ok := f[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return mx, nil
+}
+
+func TestCheck(t *testing.T) {
+	resolver := fakeResolver{
+		"gmail.com":      {{Host: "gmail-smtp-in.l.google.com.", Pref: 5}},
+		"gmial.com":      {{Host: "mx.parked.example.", Pref: 10}},
+		"outlook.es":     {{Host: "outlook-es.mail.protection.outlook.com.", Pref: 10}},
+		"mailinator.com": {{Host: "mail.mailinator.com.", Pref: 10}},
+		"nomail.org":     {{Host: ".", Pref: 0}},
+	}
+	checker := NewChecker(resolver)
+
+	tests := []struct {
+		address string
+		want    Check
+		wantErr bool
+	}{
+		{"henri@gmail.com", Check{}, false},
+		{"henri@GMAIL.com", Check{}, false},
+		{"henri@gmial.com", Check{Suggestion: "henri@gmail.com"}, false},
+		// Two typos are only corrected when the domain can't receive email
+		{"henri@hotmal.con", Check{Suggestion: "henri@hotmail.com", NoMailServer: true}, false},
+		{"henri@outlook.es", Check{}, false},
+		{"henri@mailinator.com", Check{Disposable: true}, false},
+		{"henri@nomail.org", Check{NoMailServer: true}, false},
+		{"henri@unreachable.com", Check{}, false},
+		{"henri at gmail.com", Check{}, true},
+	}
+	for _, test := range tests {
+		got, err := checker.Check(context.Background(), test.address)
+		if (err != nil) != test.wantErr {
+			t.Errorf("Check(%q) error = %v, wantErr %v", test.address, err, test.wantErr)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("Check(%q) mismatch (-want +got):\n%s", test.address, diff)
+		}
+	}
+
+	// Without a resolver, mail servers aren't checked
+	got, _ := NewChecker(nil).Check(context.Background(), "henri@gmial.com")
+	if diff := cmp.Diff(Check{Suggestion: "henri@gmail.com"}, got); diff != "" {
+		t.Errorf("Check() without a resolver mismatch (-want +got):\n%s", diff)
+	}
+}
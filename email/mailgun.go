@@ -4,45 +4,62 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/mailgun/mailgun-go/v4"
 )
 
-var domain = os.Getenv("MAIL_DOMAIN")
-var privateApiKey = os.Getenv("MAIL_GUN_PRIVATE_API_KEY")
+// Mailgun settings are read when each email is sent, so they can be pointed at a fake Mailgun (see mailguntest) after startup.
+func domain() string        { return os.Getenv("MAIL_DOMAIN") }
+func privateApiKey() string { return os.Getenv("MAIL_GUN_PRIVATE_API_KEY") }
+
+// apiBase overrides the Mailgun API URL, e.g. https://api.eu.mailgun.net/v3 or a fake Mailgun's URL.
+func apiBase() string { return os.Getenv("MAILGUN_API_BASE") }
+
+// APIBase returns the Mailgun API URL emails are sent to.
+func APIBase() string {
+	if base := apiBase(); base != "" {
+		return base
+	}
+	return mailgun.APIBase
+}
 
 type Message struct {
 	recipient string
 	sender    string
 	subject   string
 	html      string
+	headers   map[string]string
 }
 
-// SendWelcome sends a "Welcome to Operation Spark" email to the specified email address.
-func SendWelcome(to, html string) error {
+// SendWelcome sends a "Welcome to Operation Spark" email from the sender to the specified email address.
+// Headers are added to the email, e.g. List-Unsubscribe. It returns the Mailgun message ID, which the email's delivery events (see ParseWebhook) refer to.
+func SendWelcome(ctx context.Context, to, from, subject, html string, headers map[string]string) (string, error) {
 	msg := Message{
 		recipient: to,
-		sender:    fmt.Sprintf("Operation Spark <admissions@%s>", domain),
-		subject:   "Welcome from Operation Spark!",
+		sender:    from,
+		subject:   subject,
 		html:      html,
+		headers:   headers,
 	}
-	resp, err := SendSimpleMessage(domain, privateApiKey, &msg)
-	fmt.Println(resp)
-
-	if err != nil {
-		return err
-	}
-	return nil
+	return SendSimpleMessage(ctx, domain(), privateApiKey(), &msg)
 }
 
-func SendSimpleMessage(domain, apiKey string, msg *Message) (string, error) {
+// SendSimpleMessage sends the message with Mailgun and returns its message ID, without angle brackets.
+func SendSimpleMessage(ctx context.Context, domain, apiKey string, msg *Message) (string, error) {
 	mg := mailgun.NewMailgun(domain, apiKey)
+	if base := apiBase(); base != "" {
+		mg.SetAPIBase(base)
+	}
 
 	message := mg.NewMessage(msg.sender, msg.subject, "", msg.recipient)
 	message.SetHtml(msg.html)
+	for name, value := range msg.headers {
+		message.AddHeader(name, value)
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
 	defer cancel()
 
 	// Send the message with a 10 second timeout
@@ -53,5 +70,11 @@ func SendSimpleMessage(domain, apiKey string, msg *Message) (string, error) {
 	}
 
 	fmt.Printf("ID: %s Resp: %s\n", id, resp)
-	return resp, nil
+	return trimMessageId(id), nil
+}
+
+// trimMessageId removes the angle brackets around a Message-Id, e.g. "<20220314170000.1@mail.operationspark.org>".
+// Mailgun includes them when a message is sent, but not in webhook events.
+func trimMessageId(id string) string {
+	return strings.Trim(strings.TrimSpace(id), "<>")
 }
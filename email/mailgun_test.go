@@ -0,0 +1,36 @@
+package email
+
+import (
+	"context"
+	"testing"
+
+	"github.com/operationspark/slack-session-signups/email/mailguntest"
+)
+
+func TestSendWelcome(t *testing.T) {
+	mailgun := mailguntest.NewServer()
+	defer mailgun.Close()
+	t.Setenv("MAIL_DOMAIN", "mail.operationspark.org")
+	t.Setenv("MAIL_GUN_PRIVATE_API_KEY", "key-123")
+	t.Setenv("MAILGUN_API_BASE", mailgun.APIURL())
+
+	id, err := SendWelcome(context.Background(), "henri@email.com", "Operation Spark <admissions@mail.operationspark.org>", "Welcome from Operation Spark!", "<p>Hi Henri</p>", map[string]string{"List-Unsubscribe": "<https://example.com/unsubscribe>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := mailgun.Messages()
+	if len(msgs) != 1 {
+		t.Fatalf("want 1 message, got %d", len(msgs))
+	}
+	m := msgs[0]
+	if m.Domain != "mail.operationspark.org" || m.APIKey != "key-123" || len(m.To) != 1 || m.To[0] != "henri@email.com" || m.Subject != "Welcome from Operation Spark!" || m.HTML != "<p>Hi Henri</p>" {
+		t.Errorf("unexpected message: %+v", m)
+	}
+	if m.Headers["List-Unsubscribe"] != "<https://example.com/unsubscribe>" {
+		t.Errorf("want the List-Unsubscribe header, got %v", m.Headers)
+	}
+	if "<"+id+">" != m.Id {
+		t.Errorf("want message ID %s without angle brackets, got %q", m.Id, id)
+	}
+}
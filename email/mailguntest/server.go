@@ -0,0 +1,125 @@
+// Package mailguntest provides a fake Mailgun server for testing code that sends email with the Mailgun API.
+package mailguntest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// Message is an email sent to the fake Mailgun server.
+type Message struct {
+	Id      string
+	Domain  string
+	APIKey  string
+	From    string
+	To      []string
+	Subject string
+	HTML    string
+	Text    string
+	// Headers are the custom headers ("h:" parameters) and Options the sending options ("o:" parameters), e.g. "tag".
+	Headers map[string]string
+	Options map[string]string
+	// Variables are the custom variables ("v:" parameters) attached to the message and its events.
+	Variables map[string]string
+}
+
+// Server is a fake Mailgun server that records every message it's sent.
+// Point the Mailgun client at APIURL() (MAILGUN_API_BASE).
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	messages []Message
+	failure  http.Handler
+}
+
+// NewServer starts a fake Mailgun server. The caller should call Close when finished.
+func NewServer() *Server {
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// APIURL returns the base URL of the fake Mailgun API, for use as the client's API base.
+func (s *Server) APIURL() string {
+	return s.URL + "/v3"
+}
+
+// FailWith makes the server answer every request with h, e.g. a 500 or a malformed response.
+// Messages are still recorded, without an Id. FailWith(nil) restores normal responses.
+func (s *Server) FailWith(h http.Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failure = h
+}
+
+// Messages returns every message sent so far.
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message{}, s.messages...)
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	// POST /v3/{domain}/messages
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if r.Method != http.MethodPost || len(parts) != 3 || parts[0] != "v3" || parts[2] != "messages" {
+		http.NotFound(w, r)
+		return
+	}
+	_, apiKey, ok := r.BasicAuth()
+	if !ok || apiKey == "" {
+		http.Error(w, "Forbidden", http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseMultipartForm(10 << 20); err != nil && err != http.ErrNotMultipart {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	msg := Message{
+		Domain:    parts[1],
+		APIKey:    apiKey,
+		From:      r.FormValue("from"),
+		To:        r.Form["to"],
+		Subject:   r.FormValue("subject"),
+		HTML:      r.FormValue("html"),
+		Text:      r.FormValue("text"),
+		Headers:   map[string]string{},
+		Options:   map[string]string{},
+		Variables: map[string]string{},
+	}
+	for key, values := range r.Form {
+		switch {
+		case strings.HasPrefix(key, "h:"):
+			msg.Headers[strings.TrimPrefix(key, "h:")] = values[0]
+		case strings.HasPrefix(key, "o:"):
+			msg.Options[strings.TrimPrefix(key, "o:")] = values[0]
+		case strings.HasPrefix(key, "v:"):
+			msg.Variables[strings.TrimPrefix(key, "v:")] = values[0]
+		}
+	}
+	if msg.From == "" || len(msg.To) == 0 {
+		http.Error(w, `{"message": "from and to parameters are required"}`, http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	failure := s.failure
+	if failure == nil {
+		msg.Id = fmt.Sprintf("<20220314170000.%d@%s>", len(s.messages)+1, msg.Domain)
+	}
+	s.messages = append(s.messages, msg)
+	s.mu.Unlock()
+	if failure != nil {
+		failure.ServeHTTP(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"id": msg.Id, "message": "Queued. Thank you."})
+}
@@ -0,0 +1,118 @@
+package email
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// ErrInvalidSignature is returned by ParseWebhook when a webhook was not signed by Mailgun.
+var ErrInvalidSignature = errors.New("invalid Mailgun webhook signature")
+
+// maxWebhookAge limits how old a signed webhook can be, to prevent replays.
+const maxWebhookAge = 5 * time.Minute
+
+// Webhook is the body of a Mailgun event webhook.
+// https://documentation.mailgun.com/en/latest/user_manual.html#webhooks
+type Webhook struct {
+	Signature Signature `json:"signature"`
+	EventData Event     `json:"event-data"`
+}
+
+// Signature proves a webhook was sent by Mailgun: Signature is the HMAC of Timestamp and Token, keyed with the webhook signing key.
+type Signature struct {
+	Timestamp string `json:"timestamp"`
+	Token     string `json:"token"`
+	Signature string `json:"signature"`
+}
+
+// Event is something that happened to a sent email, e.g. "delivered", "failed", "opened", "clicked", or "complained".
+type Event struct {
+	Id        string  `json:"id"`
+	Event     string  `json:"event"`
+	Timestamp float64 `json:"timestamp"`
+	Recipient string  `json:"recipient"`
+	// Severity is "permanent" (a hard bounce) or "temporary" (Mailgun retries) for failed events.
+	Severity       string         `json:"severity"`
+	Reason         string         `json:"reason"`
+	DeliveryStatus DeliveryStatus `json:"delivery-status"`
+	Message        struct {
+		Headers struct {
+			MessageId string `json:"message-id"`
+		} `json:"headers"`
+	} `json:"message"`
+}
+
+// DeliveryStatus is the receiving mail server's response to a delivery attempt.
+type DeliveryStatus struct {
+	Code        int    `json:"code"`
+	Message     string `json:"message"`
+	Description string `json:"description"`
+}
+
+// MessageId returns the Mailgun message ID of the email the event is about, as returned by SendWelcome.
+func (e Event) MessageId() string {
+	return trimMessageId(e.Message.Headers.MessageId)
+}
+
+// HardBounce reports whether the event is a permanent delivery failure, e.g. the mailbox doesn't exist.
+func (e Event) HardBounce() bool {
+	return e.Event == "failed" && e.Severity == "permanent"
+}
+
+// FailureReason describes why delivery failed, preferring the receiving server's explanation.
+func (e Event) FailureReason() string {
+	switch {
+	case e.DeliveryStatus.Description != "":
+		return e.DeliveryStatus.Description
+	case e.DeliveryStatus.Message != "":
+		return e.DeliveryStatus.Message
+	}
+	return e.Reason
+}
+
+// ParseWebhook verifies that the webhook body was signed with the signing key and returns its event.
+func ParseWebhook(body []byte, signingKey string, now time.Time) (Event, error) {
+	if signingKey == "" {
+		return Event{}, errors.New("Mailgun webhook signing key not set")
+	}
+	var w Webhook
+	err := json.Unmarshal(body, &w)
+	if err != nil {
+		return Event{}, err
+	}
+	err = w.Signature.Verify(signingKey, now)
+	if err != nil {
+		return Event{}, err
+	}
+	return w.EventData, nil
+}
+
+// Verify checks the signature was made with the signing key within the last five minutes.
+func (s Signature) Verify(signingKey string, now time.Time) error {
+	unix, err := strconv.ParseInt(s.Timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	age := now.Sub(time.Unix(unix, 0))
+	if age > maxWebhookAge || age < -maxWebhookAge {
+		return ErrInvalidSignature
+	}
+
+	want := SignWebhook(signingKey, s.Timestamp, s.Token)
+	if !hmac.Equal([]byte(want), []byte(s.Signature)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// SignWebhook computes the signature Mailgun sends with a webhook's timestamp and token.
+func SignWebhook(signingKey, timestamp, token string) string {
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	mac.Write([]byte(timestamp + token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,56 @@
+package email
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseWebhook(t *testing.T) {
+	now := time.Unix(1650000000, 0)
+	webhook := func(timestamp time.Time, signature string) []byte {
+		ts := strconv.FormatInt(timestamp.Unix(), 10)
+		if signature == "" {
+			signature = SignWebhook("signing-key", ts, "token-123")
+		}
+		return []byte(fmt.Sprintf(`{
+			"signature": {"timestamp": %q, "token": "token-123", "signature": %q},
+			"event-data": {
+				"event": "failed",
+				"severity": "permanent",
+				"recipient": "henri@email.com",
+				"reason": "bounce",
+				"delivery-status": {"code": 550, "message": "", "description": "No such mailbox"},
+				"message": {"headers": {"message-id": "20220314170000.1@mail.operationspark.org"}}
+			}
+		}`, ts, signature))
+	}
+
+	tests := []struct {
+		name    string
+		body    []byte
+		key     string
+		wantErr bool
+	}{
+		{"valid", webhook(now, ""), "signing-key", false},
+		{"wrong key", webhook(now, SignWebhook("not-the-key", "1650000000", "token-123")), "signing-key", true},
+		{"stale timestamp", webhook(now.Add(-10*time.Minute), ""), "signing-key", true},
+		{"missing signature", []byte(`{"event-data": {"event": "delivered"}}`), "signing-key", true},
+		{"signing key not set", webhook(now, ""), "", true},
+		{"malformed", []byte(`<html>`), "signing-key", true},
+	}
+
+	for _, test := range tests {
+		e, err := ParseWebhook(test.body, test.key, now)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: ParseWebhook() error = %v, wantErr %v", test.name, err, test.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		if e.MessageId() != "20220314170000.1@mail.operationspark.org" || !e.HardBounce() || e.FailureReason() != "No such mailbox" {
+			t.Errorf("%s: unexpected event %+v", test.name, e)
+		}
+	}
+}
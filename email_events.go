@@ -0,0 +1,165 @@
+package signups
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/operationspark/slack-session-signups/email"
+)
+
+// MAILGUN_WEBHOOK_SIGNING_KEY verifies that email delivery events were sent by Mailgun.
+var MAILGUN_WEBHOOK_SIGNING_KEY = os.Getenv("MAILGUN_WEBHOOK_SIGNING_KEY")
+
+// emailEventRank orders the events of a delivered email, so an "opened" event that arrives after "clicked" doesn't replace it.
+var emailEventRank = map[string]int{
+	"delivered": 1,
+	"opened":    2,
+	"clicked":   3,
+}
+
+// HandleEmailEvent receives Mailgun's delivery events for welcome emails (delivered, failed, opened, clicked, complained)
+// and updates the email's delivery on the signup it was sent to. When an email hard-bounces, the signup's Slack messages get a follow-up.
+// Addresses that hard-bounce or mark an email as spam are added to the suppression list.
+// Requests must be signed with the MAILGUN_WEBHOOK_SIGNING_KEY.
+func HandleEmailEvent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if MAILGUN_WEBHOOK_SIGNING_KEY == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		return
+	}
+	e, err := email.ParseWebhook(body, MAILGUN_WEBHOOK_SIGNING_KEY, time.Now())
+	if errors.Is(err, email.ErrInvalidSignature) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error reading Mailgun event: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	records, err := signupStore.Find(r.Context(), Query{MessageId: e.MessageId()})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(records) == 0 {
+		// Other email sent from the domain. Acknowledge it so Mailgun doesn't retry.
+		fmt.Printf("no signup for Mailgun %s event, message %s\n", e.Event, e.MessageId())
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// Stop emailing addresses that bounce or complain, whichever signup the email was for
+	if reason := suppressionReason(e); reason != "" {
+		err = suppress(r.Context(), e.Recipient, reason)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Mailgun sends an email's events at about the same time, so the event is applied to the stored record atomically
+	var bounced bool
+	rec, err := signupStore.Update(r.Context(), records[len(records)-1].Id, func(rec *Record) error {
+		var changed bool
+		changed, bounced = applyEmailEvent(rec, e)
+		if !changed {
+			return errUnchanged
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errUnchanged) {
+		// Mailgun retries failed webhooks
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if bounced {
+		// The bounce is already recorded, so a Slack failure isn't retried (that would post the alert again)
+		err = postFollowUp(r.Context(), rec, "email bounced", fmt.Sprintf("%s: %s", e.Recipient, e.FailureReason()))
+		if err != nil {
+			fmt.Printf("error posting bounce for signup %s %s\n", rec.Id, err.Error())
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// errUnchanged stops an Update that has nothing to save.
+var errUnchanged = errors.New("record unchanged")
+
+// applyEmailEvent updates the delivery of the event's email on the record.
+// It reports whether the record changed, and whether the email hard-bounced for the first time.
+// Temporary failures are ignored, since Mailgun retries them.
+func applyEmailEvent(rec *Record, e email.Event) (changed, bounced bool) {
+	d := rec.emailDelivery(e.MessageId())
+	if d == nil {
+		return false, false
+	}
+
+	switch {
+	case e.HardBounce():
+		if d.Status == StatusBounced {
+			return false, false
+		}
+		d.Status = StatusBounced
+		d.Error = e.FailureReason()
+		d.Event = e.Event
+		rec.Activity = append(rec.Activity, Activity{Action: "email bounced", Note: e.FailureReason(), At: eventTime(e)})
+		return true, true
+
+	case e.Event == "complained":
+		if d.Status == StatusComplained {
+			return false, false
+		}
+		d.Status = StatusComplained
+		d.Event = e.Event
+		rec.Activity = append(rec.Activity, Activity{Action: "email marked as spam", At: eventTime(e)})
+		return true, false
+
+	case emailEventRank[e.Event] > emailEventRank[d.Event]:
+		d.Event = e.Event
+		return true, false
+	}
+	return false, false
+}
+
+// suppressionReason returns why the event's recipient should be suppressed, or "" if it shouldn't.
+func suppressionReason(e email.Event) string {
+	switch {
+	case e.HardBounce():
+		return SuppressBounced
+	case e.Event == "complained":
+		return SuppressComplained
+	}
+	return ""
+}
+
+// emailDelivery returns the record's delivery of the email with the Mailgun message ID, or nil.
+func (r *Record) emailDelivery(messageId string) *Delivery {
+	for i := len(r.Deliveries) - 1; i >= 0; i-- {
+		if r.Deliveries[i].Target == TargetEmail && r.Deliveries[i].MessageId == messageId {
+			return &r.Deliveries[i]
+		}
+	}
+	return nil
+}
+
+// eventTime returns when the event happened, or now if Mailgun didn't say.
+func eventTime(e email.Event) time.Time {
+	if e.Timestamp == 0 {
+		return time.Now().UTC()
+	}
+	return time.Unix(0, int64(e.Timestamp*float64(time.Second))).UTC()
+}
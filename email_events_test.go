@@ -0,0 +1,158 @@
+package signups
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/operationspark/slack-session-signups/email"
+	"github.com/operationspark/slack-session-signups/slack"
+	"github.com/operationspark/slack-session-signups/slack/slacktest"
+)
+
+// mailgunEvent creates a Mailgun webhook body for the event, signed with the key.
+func mailgunEvent(key, event, severity, messageId string) string {
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	return fmt.Sprintf(`{
+		"signature": {"timestamp": %q, "token": "token-123", "signature": %q},
+		"event-data": {
+			"id": "evt-1",
+			"event": %q,
+			"severity": %q,
+			"timestamp": 1647277200.5,
+			"recipient": "henri@email.com",
+			"delivery-status": {"code": 550, "description": "No such mailbox"},
+			"message": {"headers": {"message-id": %q}}
+		}
+	}`, ts, email.SignWebhook(key, ts, "token-123"), event, severity, messageId)
+}
+
+func TestHandleEmailEvent(t *testing.T) {
+	srv := slacktest.NewServer()
+	defer srv.Close()
+	defer setVar(&SLACK_BOT_TOKEN, "xoxb-test")()
+	defer setVar(&SLACK_API_URL, srv.APIURL())()
+	defer setVar(&MAILGUN_WEBHOOK_SIGNING_KEY, "signing-key")()
+	defer setStore(NewMemoryStore())()
+
+	const messageId = "20220314170000.1@mail.operationspark.org"
+	ref := slack.MessageRef{Channel: "C0SIGNUPS", TS: "1650000000.000001"}
+	rec := newRecord(Signup{NameFirst: "Henri", NameLast: "Testaroni", Email: "henri@email.com"})
+	rec.SlackMessages = []slack.MessageRef{ref}
+	rec.record(TargetGreenlight, nil)
+	rec.recordEmail(messageId, nil)
+	if err := signupStore.Save(context.Background(), rec); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		// wantEmail is the email delivery's status and latest event after the request
+		wantEmail []string
+	}{
+		{"invalid signature", mailgunEvent("not-the-key", "failed", "permanent", messageId), http.StatusUnauthorized, []string{"delivered", ""}},
+		{"malformed", `{"signature":`, http.StatusBadRequest, []string{"delivered", ""}},
+		{"other email", mailgunEvent("signing-key", "delivered", "", "other@mail.operationspark.org"), http.StatusOK, []string{"delivered", ""}},
+		{"delivered", mailgunEvent("signing-key", "delivered", "", messageId), http.StatusOK, []string{"delivered", "delivered"}},
+		{"clicked", mailgunEvent("signing-key", "clicked", "", messageId), http.StatusOK, []string{"delivered", "clicked"}},
+		{"opened after clicked", mailgunEvent("signing-key", "opened", "", messageId), http.StatusOK, []string{"delivered", "clicked"}},
+		{"temporary failure", mailgunEvent("signing-key", "failed", "temporary", messageId), http.StatusOK, []string{"delivered", "clicked"}},
+		{"hard bounce", mailgunEvent("signing-key", "failed", "permanent", messageId), http.StatusOK, []string{"bounced", "failed"}},
+		{"repeated hard bounce", mailgunEvent("signing-key", "failed", "permanent", messageId), http.StatusOK, []string{"bounced", "failed"}},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/mailgun/events", strings.NewReader(test.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		HandleEmailEvent(w, req)
+		if w.Code != test.wantStatus {
+			t.Errorf("%s: want status %d, got %d", test.name, test.wantStatus, w.Code)
+		}
+
+		got, err := signupStore.Get(context.Background(), rec.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, _ := got.Delivery(TargetEmail)
+		if diff := cmp.Diff(test.wantEmail, []string{d.Status, d.Event}); diff != "" {
+			t.Errorf("%s: email delivery mismatch (-want +got):\n%s", test.name, diff)
+		}
+	}
+
+	if s, err := signupStore.GetSuppression(context.Background(), "henri@email.com"); err != nil || s.Reason != SuppressBounced {
+		t.Errorf("want the bounced address suppressed, got %+v %v", s, err)
+	}
+
+	got, _ := signupStore.Get(context.Background(), rec.Id)
+	if len(got.Activity) != 1 || got.Activity[0].String() != "Status: email bounced (No such mailbox)" {
+		t.Errorf("want the bounce in the signup's activity, got %+v", got.Activity)
+	}
+
+	// The bounce is posted once, in the signup's thread
+	calls := srv.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("want a thread reply and an update, got %d calls", len(calls))
+	}
+	if calls[0].ThreadTS != ref.TS || calls[0].Text != "Henri Testaroni: email bounced\nhenri@email.com: No such mailbox" {
+		t.Errorf("unexpected thread reply %+v", calls[0])
+	}
+	if calls[1].Method != "chat.update" || !strings.Contains(string(calls[1].Body), "Status: email bounced (No such mailbox)") {
+		t.Errorf("unexpected update %+v", calls[1])
+	}
+}
+
+func TestHandleEmailEventConcurrent(t *testing.T) {
+	srv := slacktest.NewServer()
+	defer srv.Close()
+	defer setVar(&SLACK_BOT_TOKEN, "xoxb-test")()
+	defer setVar(&SLACK_API_URL, srv.APIURL())()
+	defer setVar(&MAILGUN_WEBHOOK_SIGNING_KEY, "signing-key")()
+	store, err := OpenSQLiteStore(filepath.Join(t.TempDir(), "signups.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	defer setStore(store)()
+
+	const messageId = "20220314170000.1@mail.operationspark.org"
+	rec := newRecord(Signup{NameFirst: "Henri", NameLast: "Testaroni", Email: "henri@email.com"})
+	rec.recordEmail(messageId, nil)
+	if err := signupStore.Save(context.Background(), rec); err != nil {
+		t.Fatal(err)
+	}
+
+	// Mailgun sends an email's events at about the same time
+	var wg sync.WaitGroup
+	for _, event := range []string{"delivered", "opened", "clicked", "failed"} {
+		wg.Add(1)
+		go func(event string) {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodPost, "/mailgun/events", strings.NewReader(mailgunEvent("signing-key", event, "permanent", messageId)))
+			w := httptest.NewRecorder()
+			HandleEmailEvent(w, req)
+			if w.Code != http.StatusOK {
+				t.Errorf("%s: want status %d, got %d %s", event, http.StatusOK, w.Code, w.Body)
+			}
+		}(event)
+	}
+	wg.Wait()
+
+	got, err := signupStore.Get(context.Background(), rec.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := got.Status(TargetEmail); status != StatusBounced || len(got.Activity) != 1 {
+		t.Errorf("want the bounce kept, got email %s and activity %+v", status, got.Activity)
+	}
+}
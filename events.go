@@ -0,0 +1,98 @@
+package signups
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/operationspark/slack-session-signups/slack"
+)
+
+// SignupEvent is something that happened to a signup after it was received, such as a cancellation.
+type SignupEvent struct {
+	Email     string `json:"email"`
+	SessionId string `json:"sessionId"`
+	// Event names what happened, e.g. "cancelled", "rescheduled", "attended", or "email bounced".
+	Event string `json:"event"`
+	Note  string `json:"note"`
+}
+
+// HandleSignupEvent receives follow-up events for a signup (from Greenlight, etc) and posts them
+// in the thread of the signup's original Slack message. Requests must carry the ADMIN_TOKEN.
+// Once the event is recorded on the signup, the request succeeds even if it couldn't be posted to Slack.
+func HandleSignupEvent(w http.ResponseWriter, r *http.Request) {
+	if !authorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var e SignupEvent
+	err := json.NewDecoder(r.Body).Decode(&e)
+	if err != nil || e.Email == "" || e.Event == "" {
+		http.Error(w, "event requires 'email' and 'event'", http.StatusBadRequest)
+		return
+	}
+
+	records, err := signupStore.Find(r.Context(), Query{Email: e.Email, SessionId: e.SessionId})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(records) == 0 {
+		http.Error(w, ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	// Events are about the most recent matching signup
+	rec, err := signupStore.Update(r.Context(), records[len(records)-1].Id, func(rec *Record) error {
+		rec.Activity = append(rec.Activity, Activity{Action: strings.ToLower(e.Event), Note: e.Note, At: time.Now().UTC()})
+		return nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The event is already recorded, so a Slack failure isn't reported to the caller (a retry would record it again)
+	err = postFollowUp(r.Context(), rec, e.Event, e.Note)
+	if err != nil {
+		fmt.Printf("error posting Slack follow-up for signup %s %s\n", rec.Id, err.Error())
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// postFollowUp replies to each of the signup's Slack messages with the event and updates the original message with the record's activity.
+// Signups without Web API messages (webhook only) get a new message in their channel(s) instead.
+func postFollowUp(ctx context.Context, rec *Record, event, note string) error {
+	s := rec.Signup
+	text := fmt.Sprintf("%s %s: %s", s.NameFirst, s.NameLast, event)
+	if note != "" {
+		text += "\n" + note
+	}
+
+	client := slackClient()
+	if client == nil || len(rec.SlackMessages) == 0 {
+		_, err := s.slackRouter().Send(ctx, s.slackAttributes(), slack.Message{Text: text})
+		return err
+	}
+
+	original := signupMessage(rec)
+	for _, ref := range rec.SlackMessages {
+		_, err := client.Reply(ctx, ref, slack.Message{Text: text})
+		if err != nil {
+			return err
+		}
+		err = client.UpdateMessage(ctx, ref, original)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package signups
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/operationspark/slack-session-signups/slack"
+	"github.com/operationspark/slack-session-signups/slack/slacktest"
+)
+
+func TestHandleSignupEvent(t *testing.T) {
+	srv := slacktest.NewServer()
+	defer srv.Close()
+	defer setVar(&SLACK_BOT_TOKEN, "xoxb-test")()
+	defer setVar(&SLACK_API_URL, srv.APIURL())()
+	defer setVar(&ADMIN_TOKEN, "admin-secret")()
+	defer setStore(NewMemoryStore())()
+
+	ref := slack.MessageRef{Channel: "C0SIGNUPS", TS: "1650000000.000001"}
+	rec := newRecord(Signup{NameFirst: "Henri", NameLast: "Testaroni", Email: "henri@email.com", SessionId: "abc123", Cohort: "is-mar-14-22-12pm"})
+	rec.SlackMessages = []slack.MessageRef{ref}
+	if err := signupStore.Save(context.Background(), rec); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		body       string
+		wantStatus int
+	}{
+		{"missing token", "", `{"email": "henri@email.com", "event": "cancelled"}`, http.StatusUnauthorized},
+		{"missing event", "admin-secret", `{"email": "henri@email.com"}`, http.StatusBadRequest},
+		{"unknown signup", "admin-secret", `{"email": "nobody@email.com", "event": "cancelled"}`, http.StatusNotFound},
+		{"cancelled", "admin-secret", `{"email": "HENRI@email.com", "sessionId": "abc123", "event": "Cancelled", "note": "Schedule conflict"}`, http.StatusNoContent},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(test.body))
+		req.Header.Set("Authorization", "Bearer "+test.token)
+		rec := httptest.NewRecorder()
+		HandleSignupEvent(rec, req)
+		if rec.Code != test.wantStatus {
+			t.Errorf("%s: want status %d, got %d", test.name, test.wantStatus, rec.Code)
+		}
+	}
+
+	calls := srv.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("want a thread reply and an update, got %d calls", len(calls))
+	}
+	if calls[0].ThreadTS != ref.TS || !strings.Contains(calls[0].Text, "Henri Testaroni: Cancelled\nSchedule conflict") {
+		t.Errorf("unexpected thread reply %+v", calls[0])
+	}
+	if calls[1].Method != "chat.update" || !strings.Contains(string(calls[1].Body), "Status: cancelled (Schedule conflict)") {
+		t.Errorf("unexpected update %+v", calls[1])
+	}
+}
+
+func TestHandleSignupEventSlackDown(t *testing.T) {
+	srv := slacktest.NewServer()
+	defer srv.Close()
+	defer setVar(&SLACK_BOT_TOKEN, "xoxb-test")()
+	defer setVar(&SLACK_API_URL, srv.APIURL())()
+	defer setVar(&ADMIN_TOKEN, "admin-secret")()
+	defer setStore(NewMemoryStore())()
+	srv.FailWith(serverError)
+
+	rec := newRecord(Signup{NameFirst: "Henri", NameLast: "Testaroni", Email: "henri@email.com"})
+	rec.SlackMessages = []slack.MessageRef{{Channel: "C0SIGNUPS", TS: "1650000000.000001"}}
+	if err := signupStore.Save(context.Background(), rec); err != nil {
+		t.Fatal(err)
+	}
+
+	// The event is recorded, so the caller isn't asked to retry it
+	req := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(`{"email": "henri@email.com", "event": "attended"}`))
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	w := httptest.NewRecorder()
+	HandleSignupEvent(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("want status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	got, err := signupStore.Get(context.Background(), rec.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Activity) != 1 || got.Activity[0].Action != "attended" {
+		t.Errorf("want the event recorded once, got %+v", got.Activity)
+	}
+}
+
+// setVar sets a package configuration variable for the duration of a test. Defer the returned func to restore it.
+func setVar(v *string, value string) func() {
+	prev := *v
+	*v = value
+	return func() { *v = prev }
+}
+
+// setStore replaces the signup store for the duration of a test. Defer the returned func to restore it.
+func setStore(s Store) func() {
+	prev := signupStore
+	signupStore = s
+	return func() { signupStore = prev }
+}
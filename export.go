@@ -0,0 +1,316 @@
+package signups
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// exportColumn is a column of a signup export.
+type exportColumn struct {
+	Name  string
+	Value func(rec *Record) string
+	// Redact hides personal information in the value when the export is redacted. Nil for columns without any.
+	Redact func(value string) string
+}
+
+// exportColumns are the columns a signup export can include, in export order.
+var exportColumns = []exportColumn{
+	{Name: "id", Value: func(rec *Record) string { return rec.Id }},
+	{Name: "receivedAt", Value: func(rec *Record) string { return rec.ReceivedAt.Format(time.RFC3339) }},
+	{Name: "programId", Value: func(rec *Record) string { return rec.Signup.Program().Id }},
+	{Name: "cohort", Value: func(rec *Record) string { return rec.Signup.Cohort }},
+	{Name: "sessionId", Value: func(rec *Record) string { return rec.Signup.SessionId }},
+	{Name: "startDateTime", Value: func(rec *Record) string {
+		if rec.Signup.StartDateTime.IsZero() {
+			return ""
+		}
+		return rec.Signup.StartDateTime.Format(time.RFC3339)
+	}},
+	{Name: "nameFirst", Value: func(rec *Record) string { return rec.Signup.NameFirst }},
+	{Name: "nameLast", Value: func(rec *Record) string { return rec.Signup.NameLast }, Redact: redactName},
+	{Name: "email", Value: func(rec *Record) string { return rec.Signup.Email }, Redact: redactEmail},
+	{Name: "cell", Value: func(rec *Record) string { return rec.Signup.Cell }, Redact: redactPhone},
+	{Name: "referrer", Value: func(rec *Record) string { return rec.Signup.Referrer }},
+	{Name: "referrerResponse", Value: func(rec *Record) string { return rec.Signup.ReferrerResponse }, Redact: redactAll},
+	{Name: "referralChannel", Value: func(rec *Record) string { return rec.Signup.Attribution().Channel }},
+	{Name: "utmSource", Value: func(rec *Record) string { return rec.Signup.Attribution().Source }},
+	{Name: "utmMedium", Value: func(rec *Record) string { return rec.Signup.Attribution().Medium }},
+	{Name: "utmCampaign", Value: func(rec *Record) string { return rec.Signup.Attribution().Campaign }},
+	{Name: "sourceIP", Value: func(rec *Record) string { return rec.SourceIP }, Redact: redactAll},
+	{Name: "assignedTo", Value: func(rec *Record) string { return rec.AssignedTo }},
+	{Name: "greenlightStatus", Value: func(rec *Record) string { return rec.Status(TargetGreenlight) }},
+	{Name: "slackStatus", Value: func(rec *Record) string { return rec.Status(TargetSlack) }},
+	{Name: "emailStatus", Value: func(rec *Record) string { return rec.Status(TargetEmail) }},
+}
+
+// defaultExportColumns are exported when no columns are selected: the contact list admissions usually asks for.
+var defaultExportColumns = []string{"receivedAt", "programId", "cohort", "startDateTime", "nameFirst", "nameLast", "email", "cell", "referralChannel"}
+
+// ExportOptions select stored signups and how they're exported.
+type ExportOptions struct {
+	Cohort    string
+	SessionId string
+	ProgramId string
+	// Since and Until select signups received in [Since, Until).
+	Since time.Time
+	Until time.Time
+	// Format is "csv" (default) or "ndjson" (a JSON object per line).
+	Format string
+	// Columns are the exported columns, in order. Empty means the default columns.
+	Columns []string
+	// Redact hides personal information: last names are shortened to an initial, and email addresses and phone numbers are partially masked.
+	Redact bool
+}
+
+// columns returns the selected export columns, or an error naming an unknown column.
+func (o ExportOptions) columns() ([]exportColumn, error) {
+	names := o.Columns
+	if len(names) == 0 {
+		names = defaultExportColumns
+	}
+	cols := []exportColumn{}
+	for _, name := range names {
+		col, ok := findExportColumn(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown column: '%s'. Use %s", name, strings.Join(ExportColumnNames(), ", "))
+		}
+		cols = append(cols, col)
+	}
+	return cols, nil
+}
+
+// validate checks the options' format and columns.
+func (o ExportOptions) validate() error {
+	switch o.Format {
+	case "", "csv", "ndjson":
+	default:
+		return fmt.Errorf("unknown format: '%s'. Use 'csv' or 'ndjson'", o.Format)
+	}
+	_, err := o.columns()
+	return err
+}
+
+func findExportColumn(name string) (exportColumn, bool) {
+	for _, col := range exportColumns {
+		if strings.EqualFold(col.Name, name) {
+			return col, true
+		}
+	}
+	return exportColumn{}, false
+}
+
+// ExportColumnNames returns the names of the columns a signup export can include.
+func ExportColumnNames() []string {
+	names := make([]string, len(exportColumns))
+	for i, col := range exportColumns {
+		names[i] = col.Name
+	}
+	return names
+}
+
+// Streamer is a Store that can read records a page at a time, so exports don't load every record into memory.
+type Streamer interface {
+	// Stream calls fn with each record matching the query, oldest first, stopping at the first error.
+	Stream(ctx context.Context, q Query, fn func(*Record) error) error
+}
+
+// eachRecord calls fn with each stored record matching the query, oldest first. Stores that aren't Streamers are read with Find.
+func eachRecord(ctx context.Context, q Query, fn func(*Record) error) error {
+	if s, ok := signupStore.(Streamer); ok {
+		return s.Stream(ctx, q, fn)
+	}
+	records, err := signupStore.Find(ctx, q)
+	if err != nil {
+		return err
+	}
+	for _, rec := range records {
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Export writes the stored signups selected by the options to w, oldest first, and returns how many were written.
+func Export(ctx context.Context, w io.Writer, opts ExportOptions) (int, error) {
+	if err := opts.validate(); err != nil {
+		return 0, err
+	}
+	cols, _ := opts.columns()
+	q := Query{Cohort: opts.Cohort, SessionId: opts.SessionId, ProgramId: opts.ProgramId, Since: opts.Since, Until: opts.Until}
+
+	values := func(rec *Record) []string {
+		row := make([]string, len(cols))
+		for i, col := range cols {
+			row[i] = col.Value(rec)
+			if opts.Redact && col.Redact != nil && row[i] != "" {
+				row[i] = col.Redact(row[i])
+			}
+		}
+		return row
+	}
+
+	n := 0
+	if opts.Format == "ndjson" {
+		err := eachRecord(ctx, q, func(rec *Record) error {
+			line, err := ndjsonLine(cols, values(rec))
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(line); err != nil {
+				return err
+			}
+			n++
+			return nil
+		})
+		return n, err
+	}
+
+	cw := csv.NewWriter(w)
+	header := make([]string, len(cols))
+	for i, col := range cols {
+		header[i] = col.Name
+	}
+	if err := cw.Write(header); err != nil {
+		return 0, err
+	}
+	err := eachRecord(ctx, q, func(rec *Record) error {
+		row := values(rec)
+		for i := range row {
+			row[i] = escapeFormula(row[i])
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	cw.Flush()
+	if err != nil {
+		return n, err
+	}
+	return n, cw.Error()
+}
+
+// escapeFormula prefixes values that spreadsheets would run as formulas with a ', e.g. "=HYPERLINK(...)" becomes "'=HYPERLINK(...)".
+// Signup fields are typed by applicants, so exported CSVs can't be trusted to be opened safely otherwise.
+func escapeFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// ndjsonLine formats a row as a JSON object with the columns in order, followed by a newline.
+func ndjsonLine(cols []exportColumn, row []string) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, col := range cols {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		name, err := json.Marshal(col.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(row[i])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(name)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteString("}\n")
+	return b.Bytes(), nil
+}
+
+// redactName shortens a name to its initial, e.g. "Testaroni" becomes "T.".
+func redactName(name string) string {
+	for _, r := range strings.TrimSpace(name) {
+		return string(r) + "."
+	}
+	return ""
+}
+
+// redactEmail masks the email address's local part, e.g. "henri@email.com" becomes "h***@email.com".
+func redactEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 1 {
+		return "***"
+	}
+	return email[:1] + "***" + email[at:]
+}
+
+// redactPhone masks all but the last 4 digits, e.g. "504-555-0100" becomes "***-***-0100".
+func redactPhone(phone string) string {
+	digits := normalizePhone(phone)
+	if len(digits) < 4 {
+		return "***"
+	}
+	return "***-***-" + digits[len(digits)-4:]
+}
+
+func redactAll(string) string {
+	return "[redacted]"
+}
+
+// HandleExport streams stored signups as CSV or NDJSON. It requires the ADMIN_TOKEN.
+// Query parameters are "cohort", "sessionId", "program", "since" and "until" (YYYY-MM-DD, including the until date, or RFC 3339), "format" ("csv" (default) or "ndjson"),
+// "columns" (comma-separated, see ExportColumnNames), and "redact=true".
+func HandleExport(w http.ResponseWriter, r *http.Request) {
+	if !authorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	q := r.URL.Query()
+	opts := ExportOptions{
+		Cohort:    q.Get("cohort"),
+		SessionId: q.Get("sessionId"),
+		ProgramId: q.Get("program"),
+		Format:    q.Get("format"),
+		Redact:    q.Get("redact") == "true",
+	}
+	for _, name := range strings.Split(q.Get("columns"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			opts.Columns = append(opts.Columns, name)
+		}
+	}
+	var err error
+	if v := q.Get("since"); v != "" {
+		if opts.Since, err = ParseSince(v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("until"); v != "" {
+		if opts.Until, err = ParseUntil(v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if err := opts.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if opts.Format == "ndjson" {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	} else {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", "attachment; filename=\"signups.csv\"")
+	}
+	_, err = Export(r.Context(), w, opts)
+	if err != nil {
+		// The response has already started, so the error can only be logged
+		fmt.Printf("error exporting signups %s\n", err.Error())
+	}
+}
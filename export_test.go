@@ -0,0 +1,145 @@
+package signups
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExport(t *testing.T) {
+	defer setStore(NewMemoryStore())()
+	received, _ := time.Parse(time.RFC3339, "2022-03-10T18:00:00Z")
+	sessionStart, _ := time.Parse(time.RFC3339, "2022-03-14T17:00:00Z")
+
+	henri := newRecord(Signup{NameFirst: "Henri", NameLast: "Testaroni", Email: "henri@email.com", Cell: "504-555-0100", Referrer: "IG", Cohort: "is-mar-14-22-12pm", StartDateTime: sessionStart})
+	henri.ReceivedAt = received
+	henri.Id = "henri"
+	henri.record(TargetGreenlight, nil)
+	workshop := newRecord(Signup{ProgramId: "workshop", NameFirst: "Solána", NameLast: "Rowe", Email: "solana@email.com", Cohort: "ws-mar-12-22-10am", StartDateTime: sessionStart})
+	workshop.ReceivedAt = received.Add(time.Hour)
+	workshop.Id = "solana"
+	formulas := newRecord(Signup{NameFirst: "=1+1", NameLast: "@SUM(A1)", Cell: "+1 504-555-0100", Cohort: "formulas"})
+	formulas.ReceivedAt = received.Add(-time.Hour)
+	for _, rec := range []*Record{henri, workshop, formulas} {
+		if err := signupStore.Save(context.Background(), rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		opts ExportOptions
+		want string
+	}{
+		{
+			name: "default columns",
+			opts: ExportOptions{Cohort: "is-mar-14-22-12pm"},
+			want: "receivedAt,programId,cohort,startDateTime,nameFirst,nameLast,email,cell,referralChannel\n" +
+				"2022-03-10T18:00:00Z,info-session,is-mar-14-22-12pm,2022-03-14T17:00:00Z,Henri,Testaroni,henri@email.com,504-555-0100,instagram\n",
+		},
+		{
+			name: "columns, by program",
+			opts: ExportOptions{ProgramId: "workshop", Columns: []string{"id", "nameFirst", "email"}},
+			want: "id,nameFirst,email\nsolana,Solána,solana@email.com\n",
+		},
+		{
+			name: "redacted",
+			opts: ExportOptions{Since: received, Columns: []string{"nameFirst", "nameLast", "email", "cell", "greenlightStatus"}, Redact: true},
+			want: "nameFirst,nameLast,email,cell,greenlightStatus\n" +
+				"Henri,T.,h***@email.com,***-***-0100,delivered\n" +
+				"Solána,R.,s***@email.com,,pending\n",
+		},
+		{
+			name: "formulas",
+			opts: ExportOptions{Cohort: "formulas", Columns: []string{"nameFirst", "nameLast", "cell"}},
+			want: "nameFirst,nameLast,cell\n'=1+1,'@SUM(A1),'+1 504-555-0100\n",
+		},
+		{
+			name: "ndjson",
+			opts: ExportOptions{Since: received.Add(time.Minute), Format: "ndjson", Columns: []string{"nameLast", "cohort"}},
+			want: `{"nameLast":"Rowe","cohort":"ws-mar-12-22-10am"}` + "\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var b bytes.Buffer
+			_, err := Export(context.Background(), &b, test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, b.String()); diff != "" {
+				t.Errorf("Export() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHandleExport(t *testing.T) {
+	defer setVar(&ADMIN_TOKEN, "admin-secret")()
+	defer setStore(NewMemoryStore())()
+
+	tests := []struct {
+		name       string
+		query      string
+		token      string
+		wantStatus int
+		wantType   string
+	}{
+		{"unauthorized", "", "wrong", http.StatusUnauthorized, ""},
+		{"unknown column", "columns=ssn", "admin-secret", http.StatusBadRequest, ""},
+		{"unknown format", "format=xlsx", "admin-secret", http.StatusBadRequest, ""},
+		{"invalid date", "since=last-week", "admin-secret", http.StatusBadRequest, ""},
+		{"csv", "cohort=is-mar-14-22-12pm", "admin-secret", http.StatusOK, "text/csv"},
+		{"ndjson", "format=ndjson&redact=true", "admin-secret", http.StatusOK, "application/x-ndjson"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/export?"+test.query, nil)
+			req.Header.Set("Authorization", "Bearer "+test.token)
+			w := httptest.NewRecorder()
+			HandleExport(w, req)
+			if w.Code != test.wantStatus {
+				t.Fatalf("want status %d, got %d: %s", test.wantStatus, w.Code, w.Body)
+			}
+			if test.wantType != "" && w.Header().Get("Content-Type") != test.wantType {
+				t.Errorf("want Content-Type %q, got %q", test.wantType, w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestHandleExportDateRange(t *testing.T) {
+	defer setVar(&ADMIN_TOKEN, "admin-secret")()
+	defer setStore(NewMemoryStore())()
+	// Noon on March 10th, Central time
+	rec := newRecord(Signup{NameFirst: "Henri"})
+	rec.Id = "henri"
+	rec.ReceivedAt, _ = time.Parse(time.RFC3339, "2022-03-10T18:00:00Z")
+	if err := signupStore.Save(context.Background(), rec); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		// An until date includes the day it names
+		{"since=2022-03-10&until=2022-03-10", "id\nhenri\n"},
+		{"until=2022-03-09", "id\n"},
+		{"until=2022-03-10T12:00:00-06:00", "id\n"},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/admin/export?columns=id&"+test.query, nil)
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		w := httptest.NewRecorder()
+		HandleExport(w, req)
+		if diff := cmp.Diff(test.want, w.Body.String()); diff != "" {
+			t.Errorf("%s: export mismatch (-want +got):\n%s", test.query, diff)
+		}
+	}
+}
@@ -6,45 +6,65 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/schema"
-	"github.com/operationspark/slack-session-signups/email"
 	"github.com/operationspark/slack-session-signups/slack"
 )
 
 var SLACK_WEBHOOK_URL = os.Getenv("SLACK_WEBHOOK_URL")
+
+// SLACK_BOT_TOKEN authorizes Slack Web API calls. Without it, Slack messages are sent with Incoming Webhooks.
+var SLACK_BOT_TOKEN = os.Getenv("SLACK_BOT_TOKEN")
+var SLACK_API_URL = envOr("SLACK_API_URL", slack.DefaultBaseURL)
+
+// SLACK_ROUTES is a JSON array of slack.Rule that send matching signups to other channels.
+var SLACK_ROUTES = os.Getenv("SLACK_ROUTES")
+
+// SIGNUP_THANK_YOU_URL is the page browsers are redirected to after a successful signup from a plain HTML form, with the signup's id.
+// Without it, form posts get the JSON response like API clients.
+var SIGNUP_THANK_YOU_URL = os.Getenv("SIGNUP_THANK_YOU_URL")
+
+// maxSignupBytes limits the size of signup request bodies. Real signups are well under 4KB.
+const maxSignupBytes = 64 << 10
+
 var decoder = schema.NewDecoder()
 
-// handleJson unmarshalls a JSON payload from a signUp request into a Signup.
+// handleJson strictly decodes a JSON payload from a signUp request into a Signup.
+// Unknown fields and invalid values are returned together as an InvalidFieldsError.
 func handleJson(s *Signup, body io.Reader) error {
-	var timeParseError *time.ParseError
-
 	b, err := io.ReadAll(body)
 	if err != nil {
 		return err
 	}
 
-	err = json.Unmarshal(b, s)
-	if errors.As(err, &timeParseError) {
-		return &InvalidFieldError{Field: "startDateTime"}
-	}
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return decodeSignupJSON(s, b)
 }
 
-// handleForm unmarshalls a FormData payload from a signUp request into a Signup
-func handleForm(s *Signup, r *http.Request) error {
-	err := r.ParseForm()
+// handleForm unmarshalls a URL-encoded or multipart FormData payload from a signUp request into a Signup.
+// Files in multipart payloads are ignored.
+func handleForm(s *Signup, r *http.Request, mediaType string) error {
+	var err error
+	if mediaType == "multipart/form-data" {
+		err = r.ParseMultipartForm(maxSignupBytes)
+		if r.MultipartForm != nil {
+			defer r.MultipartForm.RemoveAll()
+		}
+	} else {
+		err = r.ParseForm()
+	}
 	if err != nil {
 		return err
 	}
 
+	// The honeypot field is empty in real signups, and isn't a Signup field
+	r.PostForm.Del(HONEYPOT_FIELD)
 	err = decoder.Decode(s, r.PostForm)
 	if err != nil {
 		return err
@@ -53,64 +73,213 @@ func handleForm(s *Signup, r *http.Request) error {
 	return nil
 }
 
-// HandleSignUp parses Info Session sign up requests from operationspark.org.
+// HandleSignUp parses Info Session (and other program) sign up requests from operationspark.org.
 // If successful, it sends webhooks to Greenlight, Slack, other services.
+// It accepts JSON, URL-encoded form, and multipart form bodies up to maxSignupBytes.
+// The response is a JSON SignupResponse with the result of each delivery, except that browsers posting an HTML form
+// are redirected to the SIGNUP_THANK_YOU_URL when the signup succeeds.
+// Signups are rate limited per source IP (RATE_LIMIT_IP) and email address (RATE_LIMIT_EMAIL).
+// Browsers can submit signups from the CORS_ALLOWED_ORIGINS.
 func HandleSignUp(w http.ResponseWriter, r *http.Request) {
-	fmt.Println(SLACK_WEBHOOK_URL)
+	withCORS(handleSignUp)(w, r)
+}
+
+func handleSignUp(w http.ResponseWriter, r *http.Request) {
 	s := Signup{}
 
-	switch r.Header.Get("Content-Type") {
+	if wait := rateLimited(r.Context(), RATE_LIMIT_IP, "ip:"+sourceIP(r)); wait > 0 {
+		tooManySignups(w, wait)
+		return
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		writeSignupResponse(w, http.StatusUnsupportedMediaType, SignupResponse{Error: "Unacceptable Content-Type"})
+		return
+	}
+
+	// Keep the raw payload so the signup can be inspected (or re-parsed) later
+	raw, err := io.ReadAll(io.LimitReader(r.Body, maxSignupBytes+1))
+	if err != nil {
+		writeSignupResponse(w, http.StatusBadRequest, SignupResponse{Error: "Error reading request body"})
+		return
+	}
+	if len(raw) > maxSignupBytes {
+		writeSignupResponse(w, http.StatusRequestEntityTooLarge, SignupResponse{Error: "Request body too large"})
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(raw))
+
+	if honeypotFilled(r.Header.Get("Content-Type"), raw) {
+		// Look like a successful signup, so the bot doesn't learn to skip the field
+		fmt.Printf("dropping signup from %s: honeypot field %s filled\n", sourceIP(r), HONEYPOT_FIELD)
+		signedUp(w, r, mediaType, SignupResponse{Id: newId()})
+		return
+	}
+
+	// Every invalid field from decoding the signup is reported together
+	var invalid InvalidFieldsError
+	switch mediaType {
 	case "application/json":
 		err := handleJson(&s, r.Body)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			panic(err)
+		if err != nil && !errors.As(err, &invalid) {
+			writeSignupResponse(w, http.StatusBadRequest, errorResponse(err))
+			return
 		}
 
-	case "application/x-www-form-urlencoded":
-		err := handleForm(&s, r)
+	case "application/x-www-form-urlencoded", "multipart/form-data":
+		err := handleForm(&s, r, mediaType)
 		if err != nil {
-			http.Error(w, "Error reading Form Body", http.StatusBadRequest)
-			panic(err)
+			fmt.Printf("error reading form body %s\n", err.Error())
+			writeSignupResponse(w, http.StatusBadRequest, SignupResponse{Error: "Error reading Form Body"})
+			return
 		}
-		fmt.Println(s)
 
 	default:
-		http.Error(w, "Unacceptable Content-Type", http.StatusUnsupportedMediaType)
+		writeSignupResponse(w, http.StatusUnsupportedMediaType, SignupResponse{Error: "Unacceptable Content-Type"})
 		return
 	}
 
-	// Post to Greenlight
-	err := s.SignUp()
+	if len(invalid) > 0 {
+		writeSignupResponse(w, http.StatusBadRequest, errorResponse(invalid))
+		return
+	}
+	if wait := rateLimited(r.Context(), RATE_LIMIT_EMAIL, "email:"+normalizeEmail(s.Email)); wait > 0 {
+		tooManySignups(w, wait)
+		return
+	}
+
+	rec := newRequestRecord(s, r, raw)
+	rec.EmailCheck = s.checkEmail(r.Context())
+	rec.History, err = findHistory(r.Context(), rec)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		panic(err)
+		fmt.Printf("error finding previous signups %s\n", err.Error())
 	}
+	err = deliver(r.Context(), rec, emailConfirmation)
 
-	// #signups Slack notification
-	slackMsg := slack.Message{Text: s.Summary()}
-	err = slack.SendWebhook(SLACK_WEBHOOK_URL, slackMsg)
+	saveErr := signupStore.Save(r.Context(), rec)
+	if saveErr != nil {
+		fmt.Printf("error saving signup %s", saveErr.Error())
+	}
+
+	resp := SignupResponse{Id: rec.Id, Deliveries: rec.Deliveries, DidYouMean: rec.EmailCheck.Suggestion}
 	if err != nil {
-		http.Error(w, "Error sending Slack webhook", http.StatusInternalServerError)
-		panic(err)
+		resp.Error = err.Error()
+		fmt.Printf("error delivering signup %s %s\n", rec.Id, err.Error())
+		writeSignupResponse(w, http.StatusInternalServerError, resp)
+		return
 	}
+	signedUp(w, r, mediaType, resp)
+}
+
+// SignupResponse is the JSON body of HandleSignUp's responses.
+type SignupResponse struct {
+	// Id is the stored signup's ID, for looking it up (or replaying it) later.
+	Id string `json:"id,omitempty"`
+	// Deliveries are the results of sending the signup to Greenlight, Slack, and email, in order.
+	Deliveries []Delivery `json:"deliveries,omitempty"`
+	// Error is why the signup was rejected or couldn't be delivered. Field is the invalid field, if there is one.
+	Error string `json:"error,omitempty"`
+	Field string `json:"field,omitempty"`
+	// Errors are all of the invalid fields, when there is more than one problem with the signup.
+	Errors InvalidFieldsError `json:"errors,omitempty"`
+	// DidYouMean is the email address with a commonly mistyped domain corrected, so the form can ask the applicant to fix it.
+	DidYouMean string `json:"didYouMean,omitempty"`
+}
+
+// errorResponse creates the response for a signup that can't be accepted.
+func errorResponse(err error) SignupResponse {
+	resp := SignupResponse{Error: err.Error()}
+	var fieldErr *InvalidFieldError
+	if errors.As(err, &fieldErr) {
+		resp.Field = fieldErr.Field
+	}
+	var fieldsErr InvalidFieldsError
+	if errors.As(err, &fieldsErr) && len(fieldsErr) > 1 {
+		resp.Errors = fieldsErr
+	}
+	return resp
+}
+
+// tooManySignups responds that the client is rate limited, and when to try again.
+func tooManySignups(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", retryAfterSeconds(retryAfter))
+	writeSignupResponse(w, http.StatusTooManyRequests, SignupResponse{Error: "Too many signups, please try again later"})
+}
 
-	//  Send Info Session welcome email
-	buf := new(bytes.Buffer)
-	err = s.html(buf)
+// signedUp responds to a successful signup. Browsers posting an HTML form are redirected to the thank-you page,
+// and everything else (API clients, and scripts posting FormData with fetch) gets JSON.
+func signedUp(w http.ResponseWriter, r *http.Request, mediaType string, resp SignupResponse) {
+	isForm := mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data"
+	if SIGNUP_THANK_YOU_URL == "" || !isForm || !prefersHTML(r.Header.Get("Accept")) {
+		writeSignupResponse(w, http.StatusOK, resp)
+		return
+	}
+	u, err := url.Parse(SIGNUP_THANK_YOU_URL)
 	if err != nil {
-		fmt.Printf("error creating email HTML %s", err.Error())
+		fmt.Printf("invalid SIGNUP_THANK_YOU_URL %s\n", err.Error())
+		writeSignupResponse(w, http.StatusOK, resp)
+		return
+	}
+	q := u.Query()
+	q.Set("id", resp.Id)
+	u.RawQuery = q.Encode()
+	http.Redirect(w, r, u.String(), http.StatusSeeOther)
+}
+
+// prefersHTML reports whether an Accept header prefers HTML to JSON, like a browser navigating to a page does.
+// fetch's default "*/*" doesn't.
+func prefersHTML(accept string) bool {
+	htmlQ, jsonQ := 0.0, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		switch mediaType {
+		case "text/html":
+			htmlQ = q
+		case "application/json":
+			jsonQ = q
+		}
 	}
-	err = email.SendWelcome(s.Email, buf.String())
+	return htmlQ > 0 && htmlQ >= jsonQ
+}
+
+func writeSignupResponse(w http.ResponseWriter, status int, resp SignupResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(resp)
 	if err != nil {
-		fmt.Printf("error sending welcome email %s", err.Error())
+		fmt.Printf("error writing signup response %s\n", err.Error())
 	}
 }
 
+// slackClient returns a Slack Web API client if SLACK_BOT_TOKEN is set, otherwise nil.
+func slackClient() *slack.Client {
+	if SLACK_BOT_TOKEN == "" {
+		return nil
+	}
+	c := slack.NewClient(SLACK_BOT_TOKEN)
+	c.BaseURL = SLACK_API_URL
+	return c
+}
+
 type InvalidFieldError struct {
-	Field string
+	Field string `json:"field"`
+	// Reason optionally explains what is wrong with the value, e.g. "required".
+	Reason string `json:"reason,omitempty"`
 }
 
 func (e *InvalidFieldError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("invalid value for field: '%s' (%s)", e.Field, e.Reason)
+	}
 	return fmt.Sprintf("invalid value for field: '%s'", e.Field)
 }
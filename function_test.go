@@ -2,6 +2,13 @@ package signups
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -51,3 +58,191 @@ func TestHandleJson(t *testing.T) {
 		}
 	}
 }
+
+func TestHandleSignUpResponse(t *testing.T) {
+	t.Setenv("DISABLE_GREENLIGHT", "true")
+	t.Setenv("DISABLE_SLACK", "true")
+	defer setStore(NewMemoryStore())()
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+		want        SignupResponse
+	}{
+		{
+			name:        "invalid",
+			contentType: "application/json",
+			body:        `{"nameFirst": "Quinta", "nameLast": "Brunson", "email": "quinta", "startDateTime": "next tuesday"}`,
+			wantStatus:  http.StatusBadRequest,
+			want:        SignupResponse{Error: "invalid value for field: 'startDateTime' (not a date and time)", Field: "startDateTime"},
+		},
+		{
+			name:        "JSON with charset",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"nameFirst": "Quinta", "nameLast": "Brunson", "email": "quinta", "startDateTime": "next tuesday"}`,
+			wantStatus:  http.StatusBadRequest,
+			want:        SignupResponse{Error: "invalid value for field: 'startDateTime' (not a date and time)", Field: "startDateTime"},
+		},
+		{
+			name:        "malformed content type",
+			contentType: "application/json; charset",
+			body:        `{}`,
+			wantStatus:  http.StatusUnsupportedMediaType,
+			want:        SignupResponse{Error: "Unacceptable Content-Type"},
+		},
+		{
+			name:        "too large",
+			contentType: "application/json",
+			body:        `{"nameFirst": "` + strings.Repeat("Q", maxSignupBytes) + `"}`,
+			wantStatus:  http.StatusRequestEntityTooLarge,
+			want:        SignupResponse{Error: "Request body too large"},
+		},
+		{
+			name:        "unsupported content type",
+			contentType: "text/plain",
+			body:        "Quinta Brunson",
+			wantStatus:  http.StatusUnsupportedMediaType,
+			want:        SignupResponse{Error: "Unacceptable Content-Type"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(test.body))
+			req.Header.Set("Content-Type", test.contentType)
+			w := httptest.NewRecorder()
+			HandleSignUp(w, req)
+			if w.Code != test.wantStatus {
+				t.Fatalf("want status %d, got %d", test.wantStatus, w.Code)
+			}
+			var got SignupResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("want JSON response, got %q", w.Body)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("response mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("nameFirst=Quinta&nameLast=Brunson&email=quinta@email.com"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	HandleSignUp(w, req)
+	var got SignupResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("want JSON response, got %q", w.Body)
+	}
+	if w.Code != http.StatusOK || got.Id == "" || len(got.Deliveries) != len(AllTargets) {
+		t.Fatalf("want the stored signup's ID and a delivery per target, got %d %+v", w.Code, got)
+	}
+	if got.Deliveries[0].Target != TargetGreenlight || got.Deliveries[0].Status != StatusDelivered {
+		t.Errorf("want Greenlight delivered first, got %+v", got.Deliveries[0])
+	}
+}
+
+func TestHandleSignUpNegotiation(t *testing.T) {
+	t.Setenv("DISABLE_GREENLIGHT", "true")
+	t.Setenv("DISABLE_SLACK", "true")
+	defer setVar(&SIGNUP_THANK_YOU_URL, "https://operationspark.org/thank-you?program=info-session")()
+	defer setVar(&HONEYPOT_FIELD, "website")()
+	defer setStore(NewMemoryStore())()
+
+	const browserAccept = "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+	tests := []struct {
+		name         string
+		multipart    bool
+		accept       string
+		fields       map[string]string
+		wantStatus   int
+		wantRedirect bool
+	}{
+		{"multipart from a browser", true, browserAccept, map[string]string{"email": "quinta@email.com"}, http.StatusSeeOther, true},
+		{"multipart from fetch", true, "*/*", map[string]string{"email": "quinta@email.com"}, http.StatusOK, false},
+		{"form from a browser", false, browserAccept, map[string]string{"email": "quinta@email.com"}, http.StatusSeeOther, true},
+		{"form asking for JSON", false, "application/json", map[string]string{"email": "quinta@email.com"}, http.StatusOK, false},
+		{"invalid form from a browser", true, browserAccept, map[string]string{"email": "quinta@email.com", "startDateTime": "next tuesday"}, http.StatusBadRequest, false},
+		{"multipart honeypot", true, browserAccept, map[string]string{"email": "bot@email.com", "website": "spam"}, http.StatusSeeOther, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fields := map[string]string{"nameFirst": "Quinta", "nameLast": "Brunson"}
+			for k, v := range test.fields {
+				fields[k] = v
+			}
+			var body bytes.Buffer
+			contentType := "application/x-www-form-urlencoded"
+			if test.multipart {
+				mw := multipart.NewWriter(&body)
+				for k, v := range fields {
+					mw.WriteField(k, v)
+				}
+				mw.Close()
+				contentType = mw.FormDataContentType()
+			} else {
+				form := url.Values{}
+				for k, v := range fields {
+					form.Set(k, v)
+				}
+				body.WriteString(form.Encode())
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/", &body)
+			req.Header.Set("Content-Type", contentType)
+			req.Header.Set("Accept", test.accept)
+			w := httptest.NewRecorder()
+			HandleSignUp(w, req)
+			if w.Code != test.wantStatus {
+				t.Fatalf("want status %d, got %d %s", test.wantStatus, w.Code, w.Body)
+			}
+
+			location, err := url.Parse(w.Header().Get("Location"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !test.wantRedirect {
+				if location.String() != "" {
+					t.Errorf("want no redirect, got %s", location)
+				}
+				return
+			}
+			if location.Host != "operationspark.org" || location.Path != "/thank-you" ||
+				location.Query().Get("program") != "info-session" || location.Query().Get("id") == "" {
+				t.Errorf("want a redirect to the thank-you page with the signup ID, got %s", location)
+			}
+		})
+	}
+
+	// The honeypot signup was redirected but not stored
+	records, _ := signupStore.Find(context.Background(), Query{})
+	for _, r := range records {
+		if r.Signup.Email == "bot@email.com" {
+			t.Errorf("want the honeypot signup dropped, got %+v", r.Signup)
+		}
+	}
+	if len(records) != 4 {
+		t.Errorf("want 4 stored signups, got %d", len(records))
+	}
+}
+
+func TestPrefersHTML(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", true},
+		{"text/html", true},
+		{"*/*", false},
+		{"", false},
+		{"application/json", false},
+		{"application/json, text/html;q=0.5", false},
+		{"text/html;q=0", false},
+		{"text/html;q=oops", false},
+	}
+	for _, test := range tests {
+		if got := prefersHTML(test.accept); got != test.want {
+			t.Errorf("prefersHTML(%q) = %v, want %v", test.accept, got, test.want)
+		}
+	}
+}
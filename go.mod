@@ -3,22 +3,21 @@ module github.com/operationspark/slack-session-signups
 go 1.17
 
 require (
-	github.com/GoogleCloudPlatform/functions-framework-go v1.5.3
+	github.com/alicebob/miniredis/v2 v2.35.0
+	github.com/gomodule/redigo v1.8.5
 	github.com/google/go-cmp v0.5.6
 	github.com/gorilla/schema v1.2.0
 	github.com/mailgun/mailgun-go/v4 v4.6.0
+	github.com/mattn/go-sqlite3 v1.14.12
 )
 
 require (
-	cloud.google.com/go/functions v1.0.0 // indirect
-	github.com/cloudevents/sdk-go/v2 v2.6.1 // indirect
-	github.com/google/uuid v1.1.2 // indirect
 	github.com/gorilla/mux v1.8.0 // indirect
 	github.com/json-iterator/go v1.1.10 // indirect
 	github.com/modern-go/concurrent v0.0.0-20180228061459-e0a39a4cb421 // indirect
 	github.com/modern-go/reflect2 v0.0.0-20180701023420-4b7aa43c6742 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
-	go.uber.org/atomic v1.4.0 // indirect
-	go.uber.org/multierr v1.1.0 // indirect
-	go.uber.org/zap v1.10.0 // indirect
+	github.com/stretchr/testify v1.6.1 // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
+	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
 )
@@ -2,33 +2,51 @@ package signups
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"net/http"
 	"os"
 )
 
-// SignUp (verb) sends a webhook to Greenlight (POST /signup).
-// The webhook creates a Info Session Signup record in the Greenlight database.
-func (s *Signup) SignUp() error {
+// greenlightSignup is the Greenlight webhook body: the Signup, its referral channel, and the person's earlier signups.
+type greenlightSignup struct {
+	*Signup
+	ReferralChannel string        `json:"referralChannel"`
+	PreviousSignups []PriorSignup `json:"previousSignups"`
+}
+
+// SignUp (verb) sends a webhook to the Signup's program Greenlight endpoint (POST /signup).
+// The webhook creates a Signup record (Info Session, Workshop, etc) in the Greenlight database.
+// History is the person's earlier signups, so Greenlight can link them.
+func (s *Signup) SignUp(ctx context.Context, history []PriorSignup) error {
 	if os.Getenv("DISABLE_GREENLIGHT") == "true" {
 		return nil
 	}
-	url, ok := os.LookupEnv("GREENLIGHT_WEBHOOK_URL")
-	if !ok {
-		return errors.New("'GREENLIGHT_WEBHOOK_URL' env var not set")
+	p := s.Program()
+	url := p.GreenlightURL
+	if url == "" {
+		return fmt.Errorf("no Greenlight webhook URL set for program '%s'. Check the 'GREENLIGHT_WEBHOOK_URL' env var", p.Id)
 	}
 
-	body, err := json.Marshal(s)
+	if history == nil {
+		history = []PriorSignup{}
+	}
+	body, err := json.Marshal(greenlightSignup{Signup: s, ReferralChannel: s.Attribution().Channel, PreviousSignups: history})
 	if err != nil {
 		return err
 	}
 
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode >= 300 {
 		return fmt.Errorf("could not post to Greenlight\n%s", resp.Status)
@@ -0,0 +1,78 @@
+// Package greenlighttest provides a fake Greenlight signup webhook for testing code that posts signups to Greenlight.
+package greenlighttest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// Request is a signup posted to the fake Greenlight webhook.
+type Request struct {
+	Path        string
+	ContentType string
+	Body        json.RawMessage
+}
+
+// Server is a fake Greenlight webhook that records every signup it receives.
+// Signups are accepted at any path, e.g. WebhookURL() for GREENLIGHT_WEBHOOK_URL.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []Request
+	failure  http.Handler
+}
+
+// NewServer starts a fake Greenlight server. The caller should call Close when finished.
+func NewServer() *Server {
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// WebhookURL returns the URL of the fake signup webhook.
+func (s *Server) WebhookURL() string {
+	return s.URL + "/signup"
+}
+
+// FailWith makes the server answer every request with h, e.g. a 500 or a malformed response, after recording it.
+// FailWith(nil) restores normal responses.
+func (s *Server) FailWith(h http.Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failure = h
+}
+
+// Requests returns every signup received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request{}, s.requests...)
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil || !json.Valid(body) {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{Path: r.URL.Path, ContentType: r.Header.Get("Content-Type"), Body: body})
+	failure := s.failure
+	s.mu.Unlock()
+	if failure != nil {
+		failure.ServeHTTP(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"ok": true}`))
+}
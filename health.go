@@ -0,0 +1,193 @@
+package signups
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/operationspark/slack-session-signups/email"
+	"github.com/operationspark/slack-session-signups/slack"
+)
+
+// Version, Commit, and BuildTime describe the build. They're set when the server is built, e.g.
+//
+//	go build -ldflags "-X github.com/operationspark/slack-session-signups.Commit=$(git rev-parse HEAD) -X github.com/operationspark/slack-session-signups.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
+
+// Pinger is a Store that can check its connection. Stores that can't fail (e.g. the memory store) aren't Pingers.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// ReadyCheck is the result of one of /readyz's checks. Error is empty if the check passed.
+type ReadyCheck struct {
+	Name  string `json:"name"`
+	Error string `json:"error,omitempty"`
+}
+
+// ReadyResponse is the JSON body of HandleReady's responses.
+type ReadyResponse struct {
+	Ready  bool         `json:"ready"`
+	Checks []ReadyCheck `json:"checks"`
+}
+
+// VersionResponse is the JSON body of HandleVersion's responses.
+type VersionResponse struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"buildTime"`
+	GoVersion string `json:"goVersion"`
+}
+
+// HandleHealth reports that the server is running, for liveness checks. It doesn't check configuration or downstream services.
+func HandleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// HandleVersion reports the build's Version, Commit, and BuildTime.
+func HandleVersion(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, VersionResponse{Version: Version, Commit: Commit, BuildTime: BuildTime, GoVersion: runtime.Version()})
+}
+
+// HandleReady reports whether the server can take signups: the settings signups need are set and valid, and the signup store
+// can be reached. With ?downstream=true, it also checks that Greenlight, Slack, and Mailgun (or their fakes) can be reached.
+// It responds 503 Service Unavailable if any check fails.
+func HandleReady(w http.ResponseWriter, r *http.Request) {
+	checks := []ReadyCheck{readyCheck("config", configProblems()), readyCheck("store", pingStore(r.Context()))}
+	if r.URL.Query().Get("downstream") == "true" {
+		checks = append(checks, pingDownstreams(r.Context())...)
+	}
+
+	resp := ReadyResponse{Ready: true, Checks: checks}
+	for _, c := range checks {
+		if c.Error != "" {
+			resp.Ready = false
+		}
+	}
+	status := http.StatusOK
+	if !resp.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, resp)
+}
+
+// readyCheck creates a check from its problems.
+func readyCheck(name string, problems []string) ReadyCheck {
+	return ReadyCheck{Name: name, Error: strings.Join(problems, "; ")}
+}
+
+// configProblems returns the settings signups need that are missing or invalid.
+func configProblems() []string {
+	problems := []string{}
+	required := []string{"MAIL_DOMAIN", "MAIL_GUN_PRIVATE_API_KEY"}
+	if os.Getenv("DISABLE_GREENLIGHT") != "true" {
+		required = append(required, "GREENLIGHT_WEBHOOK_URL")
+	}
+	for _, key := range required {
+		if os.Getenv(key) == "" {
+			problems = append(problems, key+" is not set")
+		}
+	}
+	if os.Getenv("DISABLE_SLACK") != "true" && SLACK_WEBHOOK_URL == "" && SLACK_BOT_TOKEN == "" {
+		problems = append(problems, "SLACK_WEBHOOK_URL or SLACK_BOT_TOKEN is not set")
+	}
+
+	for key, limit := range map[string]string{"RATE_LIMIT_EMAIL": RATE_LIMIT_EMAIL, "RATE_LIMIT_IP": RATE_LIMIT_IP} {
+		if _, _, err := parseLimit(limit); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", key, err.Error()))
+		}
+	}
+	if _, err := slack.ParseRules(SLACK_ROUTES); err != nil {
+		problems = append(problems, fmt.Sprintf("SLACK_ROUTES: %s", err.Error()))
+	}
+	if d, err := time.ParseDuration(DOWNSTREAM_TIMEOUT); DOWNSTREAM_TIMEOUT != "" && (err != nil || d <= 0) {
+		problems = append(problems, fmt.Sprintf("DOWNSTREAM_TIMEOUT: invalid duration %q", DOWNSTREAM_TIMEOUT))
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// pingStore checks that the signup store can be reached.
+func pingStore(ctx context.Context) []string {
+	p, ok := signupStore.(Pinger)
+	if !ok {
+		return nil
+	}
+	if err := p.Ping(ctx); err != nil {
+		return []string{err.Error()}
+	}
+	return nil
+}
+
+// pingDownstreams checks that each downstream service signups are delivered to answers HTTP requests.
+// Any response counts, since the endpoints only accept real (authenticated) requests.
+func pingDownstreams(ctx context.Context) []ReadyCheck {
+	urls := map[string][]string{"mailgun": {email.APIBase()}}
+	if os.Getenv("DISABLE_GREENLIGHT") != "true" {
+		seen := map[string]bool{}
+		for _, p := range Programs() {
+			if p.GreenlightURL != "" && !seen[p.GreenlightURL] {
+				seen[p.GreenlightURL] = true
+				urls["greenlight"] = append(urls["greenlight"], p.GreenlightURL)
+			}
+		}
+		sort.Strings(urls["greenlight"])
+	}
+	if os.Getenv("DISABLE_SLACK") != "true" {
+		if SLACK_BOT_TOKEN != "" {
+			urls["slack"] = []string{SLACK_API_URL}
+		} else if SLACK_WEBHOOK_URL != "" {
+			urls["slack"] = []string{SLACK_WEBHOOK_URL}
+		}
+	}
+
+	checks := []ReadyCheck{}
+	for _, name := range []string{"greenlight", "slack", "mailgun"} {
+		if len(urls[name]) == 0 {
+			continue
+		}
+		problems := []string{}
+		for _, u := range urls[name] {
+			if err := ping(ctx, u); err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+		checks = append(checks, readyCheck(name, problems))
+	}
+	return checks
+}
+
+// ping sends a HEAD request to the URL, within the DOWNSTREAM_TIMEOUT.
+func ping(ctx context.Context, url string) error {
+	ctx, cancel := context.WithTimeout(ctx, downstreamTimeout())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		fmt.Printf("error writing response %s\n", err.Error())
+	}
+}
@@ -0,0 +1,111 @@
+package signups
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHandleHealth(t *testing.T) {
+	w := httptest.NewRecorder()
+	HandleHealth(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "{\"status\":\"ok\"}\n" {
+		t.Errorf("want 200 ok, got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestHandleVersion(t *testing.T) {
+	defer setVar(&Version, "1.4.0")()
+	defer setVar(&Commit, "4339898")()
+	defer setVar(&BuildTime, "2022-03-14T17:00:00Z")()
+
+	w := httptest.NewRecorder()
+	HandleVersion(w, httptest.NewRequest(http.MethodGet, "/version", nil))
+	var got VersionResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("want a JSON response, got %q", w.Body)
+	}
+	want := VersionResponse{Version: "1.4.0", Commit: "4339898", BuildTime: "2022-03-14T17:00:00Z", GoVersion: runtime.Version()}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("version mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestHandleReady(t *testing.T) {
+	env := newIntegrationEnv(t)
+
+	ready := func(t *testing.T, query string) (int, ReadyResponse) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		HandleReady(w, httptest.NewRequest(http.MethodGet, "/readyz"+query, nil))
+		var resp ReadyResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("want a JSON response, got %q", w.Body)
+		}
+		return w.Code, resp
+	}
+
+	t.Run("configured", func(t *testing.T) {
+		status, resp := ready(t, "")
+		want := ReadyResponse{Ready: true, Checks: []ReadyCheck{{Name: "config"}, {Name: "store"}}}
+		if diff := cmp.Diff(want, resp); status != http.StatusOK || diff != "" {
+			t.Errorf("want 200, got %d (-want +got):\n%s", status, diff)
+		}
+	})
+
+	t.Run("SQLite store", func(t *testing.T) {
+		s, err := OpenSQLiteStore(filepath.Join(t.TempDir(), "signups.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer setStore(s)()
+		if status, _ := ready(t, ""); status != http.StatusOK {
+			t.Errorf("want 200 with an open database, got %d", status)
+		}
+		s.Close()
+		status, resp := ready(t, "")
+		if status != http.StatusServiceUnavailable || resp.Ready || resp.Checks[1].Error == "" {
+			t.Errorf("want 503 with a closed database, got %d %+v", status, resp)
+		}
+	})
+
+	t.Run("misconfigured", func(t *testing.T) {
+		t.Setenv("MAIL_GUN_PRIVATE_API_KEY", "")
+		defer setVar(&RATE_LIMIT_IP, "20 an hour")()
+		defer setVar(&SLACK_WEBHOOK_URL, "")()
+		defer setVar(&SLACK_BOT_TOKEN, "")()
+
+		status, resp := ready(t, "")
+		want := ReadyResponse{Checks: []ReadyCheck{
+			{Name: "config", Error: `MAIL_GUN_PRIVATE_API_KEY is not set; RATE_LIMIT_IP: invalid rate limit "20 an hour", want count/duration (e.g. 20/1h); SLACK_WEBHOOK_URL or SLACK_BOT_TOKEN is not set`},
+			{Name: "store"},
+		}}
+		if diff := cmp.Diff(want, resp); status != http.StatusServiceUnavailable || diff != "" {
+			t.Errorf("want 503, got %d (-want +got):\n%s", status, diff)
+		}
+	})
+
+	t.Run("downstream", func(t *testing.T) {
+		status, resp := ready(t, "?downstream=true")
+		want := ReadyResponse{Ready: true, Checks: []ReadyCheck{{Name: "config"}, {Name: "store"}, {Name: "greenlight"}, {Name: "slack"}, {Name: "mailgun"}}}
+		if diff := cmp.Diff(want, resp); status != http.StatusOK || diff != "" {
+			t.Errorf("want 200, got %d (-want +got):\n%s", status, diff)
+		}
+
+		env.greenlight.Close()
+		status, resp = ready(t, "?downstream=true")
+		if status != http.StatusServiceUnavailable || resp.Checks[2].Name != "greenlight" || resp.Checks[2].Error == "" {
+			t.Errorf("want 503 with Greenlight down, got %d %+v", status, resp)
+		}
+		for _, c := range resp.Checks {
+			if c.Name != "greenlight" && c.Error != "" {
+				t.Errorf("want only Greenlight down, got %+v", c)
+			}
+		}
+	})
+}
@@ -0,0 +1,325 @@
+package signups
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// importColumnAliases map normalized spreadsheet column headers to Signup JSON fields.
+// Headers that are already field names (in any case) don't need an alias.
+var importColumnAliases = map[string]string{
+	"first name":                "nameFirst",
+	"last name":                 "nameLast",
+	"email address":             "email",
+	"phone":                     "cell",
+	"phone number":              "cell",
+	"cell phone":                "cell",
+	"session":                   "cohort",
+	"session date":              "startDateTime",
+	"program":                   "programId",
+	"how did you hear about us": "referrer",
+	"referral":                  "referrer",
+	"timestamp":                 importReceivedAt,
+	"received":                  importReceivedAt,
+	"utm source":                "utmSource",
+	"utm medium":                "utmMedium",
+	"utm campaign":              "utmCampaign",
+	"landing page":              "landingPage",
+}
+
+// importReceivedAt is the import column for when the signup was originally received, e.g. a Google Form's "Timestamp".
+const importReceivedAt = "receivedAt"
+
+// ImportRow is a signup read from an import file.
+type ImportRow struct {
+	// Line is the row's line (CSV) or array index (JSON), counting from 1, for error messages.
+	Line   int
+	Signup Signup
+	// ReceivedAt is when the signup was originally received, if the file has it.
+	ReceivedAt time.Time
+	// Fields are the row's values by Signup JSON field, as read from the file.
+	Fields map[string]string
+	// Err is why the row couldn't be read, e.g. an invalid field.
+	Err error
+}
+
+// ReadImport reads signups from a CSV file with a header row, or a JSON array of objects.
+// Columns are mapped onto Signup fields by mapping (column to JSON field name), then by importColumnAliases and field names.
+// It returns the rows and the columns that didn't map to any field. Rows that HandleSignUp would reject have an Err.
+func ReadImport(r io.Reader, format string, mapping map[string]string) ([]ImportRow, []string, error) {
+	var records []map[string]string
+	var err error
+	switch format {
+	case "csv":
+		records, err = readImportCSV(r)
+	case "json":
+		err = json.NewDecoder(r).Decode(&records)
+	default:
+		return nil, nil, fmt.Errorf("unknown import format: '%s'. Use 'csv' or 'json'", format)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fields := signupFields()
+	for column, field := range mapping {
+		if !fields[field] && field != importReceivedAt {
+			return nil, nil, fmt.Errorf("column %q is mapped to unknown field %q", column, field)
+		}
+	}
+	field := func(column string) string {
+		if f, ok := mapping[column]; ok {
+			return f
+		}
+		key := normalizeReferral(column)
+		if f, ok := importColumnAliases[key]; ok {
+			return f
+		}
+		compact := strings.ReplaceAll(key, " ", "")
+		for f := range fields {
+			if strings.EqualFold(f, compact) {
+				return f
+			}
+		}
+		if strings.EqualFold(importReceivedAt, compact) {
+			return importReceivedAt
+		}
+		return ""
+	}
+
+	unmapped := map[string]bool{}
+	rows := []ImportRow{}
+	for i, record := range records {
+		row := ImportRow{Line: i + 1, Fields: map[string]string{}}
+		if format == "csv" {
+			// Line 1 is the header
+			row.Line = i + 2
+		}
+		for column, value := range record {
+			f := field(column)
+			if f == "" {
+				unmapped[column] = true
+				continue
+			}
+			row.Fields[f] = strings.TrimSpace(value)
+		}
+		row.Signup, row.ReceivedAt, row.Err = parseImportRow(row.Fields)
+		rows = append(rows, row)
+	}
+
+	columns := []string{}
+	for column := range unmapped {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	return rows, columns, nil
+}
+
+// readImportCSV reads CSV rows as maps of header to value.
+func readImportCSV(r io.Reader) ([]map[string]string, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV header: %w", err)
+	}
+	records := []map[string]string{}
+	for {
+		values, err := cr.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		record := map[string]string{}
+		for i, value := range values {
+			record[header[i]] = value
+		}
+		records = append(records, record)
+	}
+}
+
+// parseImportRow parses a row's fields into a Signup the same way HandleSignUp parses JSON signups.
+func parseImportRow(fields map[string]string) (Signup, time.Time, error) {
+	var s Signup
+	var receivedAt time.Time
+	values := map[string]interface{}{}
+	for f, v := range fields {
+		switch {
+		case v == "":
+		case f == importReceivedAt:
+			t, err := parseImportTime(v)
+			if err != nil {
+				return s, receivedAt, &InvalidFieldError{Field: importReceivedAt}
+			}
+			receivedAt = t
+		case f == "startDateTime":
+			t, err := parseImportTime(v)
+			if err != nil {
+				return s, receivedAt, &InvalidFieldError{Field: "startDateTime"}
+			}
+			values[f] = t
+		default:
+			values[f] = v
+		}
+	}
+	b, err := json.Marshal(values)
+	if err != nil {
+		return s, receivedAt, err
+	}
+	return s, receivedAt, handleJson(&s, bytes.NewReader(b))
+}
+
+// parseImportTime parses an RFC 3339 time, or a spreadsheet date and time in Central time (e.g. Google Forms' "3/14/2022 12:00:00").
+func parseImportTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	var err error
+	for _, layout := range []string{"1/2/2006 15:04:05", "1/2/2006 3:04 PM", "1/2/2006", "2006-01-02 15:04", "2006-01-02"} {
+		var t time.Time
+		t, err = time.ParseInLocation(layout, value, centralTZ())
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// signupFields returns the Signup's JSON field names.
+func signupFields() map[string]bool {
+	fields := map[string]bool{}
+	t := reflect.TypeOf(Signup{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			fields[name] = true
+		}
+	}
+	return fields
+}
+
+// ImportOptions control how imported signups are delivered.
+type ImportOptions struct {
+	// Interval is the minimum time between deliveries, so Greenlight, Slack, and Mailgun aren't flooded.
+	Interval time.Duration
+	// DryRun validates the rows without delivering or storing anything.
+	DryRun bool
+}
+
+// ImportResult is the outcome of importing a row.
+type ImportResult struct {
+	Line  int    `json:"line"`
+	Email string `json:"email"`
+	// Result is "imported", "failed" (a delivery failed), "invalid", "skipped" (already stored), or "would import" (dry run).
+	Result string `json:"result"`
+	Id     string `json:"id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// String formats the result as a line of command output.
+func (r ImportResult) String() string {
+	s := fmt.Sprintf("%d\t%s\t%s", r.Line, r.Email, r.Result)
+	if r.Id != "" {
+		s += "\t" + r.Id
+	}
+	if r.Error != "" {
+		s += "\t" + r.Error
+	}
+	return s
+}
+
+// findImported returns the stored signup from the same person (by email or phone) for the same session, or nil.
+// Query treats empty fields as "any", so the session and cohort are compared here: a signup without a session
+// only matches another signup without one.
+func findImported(ctx context.Context, s *Signup) (*Record, error) {
+	a := s.applicant()
+	if a == (Applicant{}) {
+		return nil, nil
+	}
+	records, err := signupStore.Find(ctx, Query{Applicant: a, SessionId: s.SessionId, Cohort: s.Cohort})
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range records {
+		if r.Signup.SessionId == s.SessionId && strings.EqualFold(r.Signup.Cohort, s.Cohort) {
+			return r, nil
+		}
+	}
+	return nil, nil
+}
+
+// Import delivers the rows' signups through the normal pipeline (Greenlight, Slack, then the welcome email) and stores them.
+// Rows already in the store (same person and session) are skipped, so an interrupted import can be run again.
+func Import(ctx context.Context, rows []ImportRow, opts ImportOptions) ([]ImportResult, error) {
+	results := []ImportResult{}
+	var last time.Time
+	for _, row := range rows {
+		result := ImportResult{Line: row.Line, Email: row.Signup.Email}
+		if row.Err != nil {
+			result.Result, result.Error = "invalid", row.Err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		stored, err := findImported(ctx, &row.Signup)
+		if err != nil {
+			return results, err
+		}
+		if stored != nil {
+			result.Result, result.Id = "skipped", stored.Id
+			results = append(results, result)
+			continue
+		}
+		if opts.DryRun {
+			result.Result = "would import"
+			results = append(results, result)
+			continue
+		}
+
+		if wait := opts.Interval - time.Since(last); !last.IsZero() && wait > 0 {
+			select {
+			case <-ctx.Done():
+				return results, ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+		last = time.Now()
+
+		rec := newRecord(row.Signup)
+		if !row.ReceivedAt.IsZero() {
+			rec.ReceivedAt = row.ReceivedAt.UTC()
+		}
+		rec.RawPayload, _ = json.Marshal(row.Fields)
+		rec.ContentType = "application/json"
+		rec.UserAgent = "signups import"
+		rec.EmailCheck = rec.Signup.checkEmail(ctx)
+		rec.History, err = findHistory(ctx, rec)
+		if err != nil {
+			fmt.Printf("error finding previous signups %s\n", err.Error())
+		}
+
+		result.Id, result.Result = rec.Id, "imported"
+		err = deliver(ctx, rec, emailConfirmation)
+		if err == nil && len(rec.Failed()) > 0 {
+			err = fmt.Errorf("%s delivery failed", strings.Join(rec.Failed(), ", "))
+		}
+		if err != nil {
+			result.Result, result.Error = "failed", err.Error()
+		}
+		if err := signupStore.Save(ctx, rec); err != nil {
+			return append(results, result), fmt.Errorf("error saving signup %s: %w", rec.Id, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
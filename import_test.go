@@ -0,0 +1,152 @@
+package signups
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/operationspark/slack-session-signups/slack/slacktest"
+)
+
+const importCSV = `Timestamp,First Name,Last Name,Email Address,Phone Number,Session,sessionId,Session Date,Favorite Color
+3/14/2022 9:30:00,Henri,Testaroni,henri@email.com,504-555-0100,is-mar-14-22-12pm,s1,3/14/2022 12:00:00,blue
+3/14/2022 9:45:00,Yasiin,Bey,yasiin@email.com,504-555-0101,is-mar-14-22-12pm,s1,next tuesday,green
+3/14/2022 10:00:00,Solána,Rowe,solana@email.com,,,,,
+`
+
+func TestReadImport(t *testing.T) {
+	rows, unmapped, err := ReadImport(strings.NewReader(importCSV), "csv", map[string]string{"Favorite Color": "referrerResponse"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unmapped) != 0 {
+		t.Errorf("want every column mapped, got unmapped %v", unmapped)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("want 3 rows, got %d", len(rows))
+	}
+
+	sessionStart, _ := time.Parse(time.RFC3339, "2022-03-14T17:00:00Z")
+	received, _ := time.Parse(time.RFC3339, "2022-03-14T14:30:00Z")
+	want := Signup{NameFirst: "Henri", NameLast: "Testaroni", Email: "henri@email.com", Cell: "504-555-0100", Cohort: "is-mar-14-22-12pm", SessionId: "s1", StartDateTime: sessionStart, ReferrerResponse: "blue"}
+	if diff := cmp.Diff(want, rows[0].Signup, cmp.Comparer(func(a, b time.Time) bool { return a.Equal(b) })); diff != "" {
+		t.Errorf("row 2 mismatch (-want +got):\n%s", diff)
+	}
+	if !rows[0].ReceivedAt.Equal(received) || rows[0].Line != 2 || rows[0].Err != nil {
+		t.Errorf("want valid line 2 received %s, got line %d received %s, error %v", received, rows[0].Line, rows[0].ReceivedAt, rows[0].Err)
+	}
+	var fieldErr *InvalidFieldError
+	if !errors.As(rows[1].Err, &fieldErr) || fieldErr.Field != "startDateTime" {
+		t.Errorf("want line 3 invalid session date, got %v", rows[1].Err)
+	}
+	if rows[2].Err != nil || !rows[2].Signup.StartDateTime.IsZero() {
+		t.Errorf("want line 4 valid without a session, got %+v", rows[2])
+	}
+
+	_, unmapped, err = ReadImport(strings.NewReader(importCSV), "csv", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"Favorite Color"}, unmapped); diff != "" {
+		t.Errorf("unmapped columns mismatch (-want +got):\n%s", diff)
+	}
+
+	rows, _, err = ReadImport(strings.NewReader(`[{"nameFirst": "Amir", "nameLast": "Thompson", "email": "amir@email.com"}]`), "json", nil)
+	if err != nil || len(rows) != 1 || rows[0].Err != nil || rows[0].Signup.NameFirst != "Amir" {
+		t.Errorf("want 1 valid JSON row, got %+v (%v)", rows, err)
+	}
+
+	if _, _, err := ReadImport(strings.NewReader(importCSV), "csv", map[string]string{"Favorite Color": "color"}); err == nil {
+		t.Error("want an error mapping a column to an unknown field")
+	}
+}
+
+func TestImport(t *testing.T) {
+	var mu sync.Mutex
+	greenlightPosts := 0
+	greenlight := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		greenlightPosts++
+	}))
+	defer greenlight.Close()
+	slackSrv := slacktest.NewServer()
+	defer slackSrv.Close()
+
+	t.Setenv("GREENLIGHT_WEBHOOK_URL", greenlight.URL)
+	defer setVar(&SLACK_WEBHOOK_URL, slackSrv.WebhookURL("signups"))()
+	defer setVar(&SLACK_BOT_TOKEN, "")()
+	defer setStore(NewMemoryStore())()
+	ctx := context.Background()
+
+	rows, _, err := ReadImport(strings.NewReader(importCSV), "csv", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := Import(ctx, rows, ImportOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, r := range results {
+		got = append(got, r.Result)
+	}
+	if diff := cmp.Diff([]string{"would import", "invalid", "would import"}, got); diff != "" {
+		t.Errorf("dry run mismatch (-want +got):\n%s", diff)
+	}
+	if greenlightPosts != 0 || len(slackSrv.Calls()) != 0 {
+		t.Fatal("dry run should not deliver anything")
+	}
+
+	start := time.Now()
+	results, err = Import(ctx, rows, ImportOptions{Interval: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("want deliveries at least 50ms apart, took %s", elapsed)
+	}
+	if greenlightPosts != 2 || len(slackSrv.Calls()) != 2 {
+		t.Errorf("want 2 Greenlight and Slack posts, got %d and %d", greenlightPosts, len(slackSrv.Calls()))
+	}
+	// Mailgun isn't configured in tests, so the welcome emails fail
+	if results[0].Result != "failed" || results[0].Error != "email delivery failed" || results[0].Id == "" {
+		t.Errorf("want line 2 delivered except for the email, got %+v", results[0])
+	}
+	rec, err := signupStore.Get(ctx, results[0].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Status(TargetGreenlight) != StatusDelivered || rec.ReceivedAt.Format(time.RFC3339) != "2022-03-14T14:30:00Z" {
+		t.Errorf("want stored signup delivered to Greenlight, received at the sheet's timestamp, got %+v", rec)
+	}
+
+	// Stored signups are skipped when the file is imported again
+	results, err = Import(ctx, rows, ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Result != "skipped" || results[2].Result != "skipped" || greenlightPosts != 2 {
+		t.Errorf("want stored signups skipped, got %v", results)
+	}
+
+	// Empty sessions only match empty sessions: Henri hasn't signed up without one, and Solána hasn't for s1
+	rows, _, err = ReadImport(strings.NewReader(`[{"nameFirst": "Henri", "email": "henri@email.com"}, {"nameFirst": "Solána", "email": "solana@email.com", "sessionId": "s1", "cohort": "is-mar-14-22-12pm"}]`), "json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err = Import(ctx, rows, ImportOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Result != "would import" || results[1].Result != "would import" {
+		t.Errorf("want signups for other sessions imported, got %v", results)
+	}
+}
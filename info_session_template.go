@@ -2,6 +2,7 @@ package signups
 
 type WelcomeValues struct {
 	DisplayName string
+	ProgramName string
 	SessionDate string
 	SessionTime string
 }
@@ -14,7 +15,7 @@ const InfoSessionHtml = `<!DOCTYPE html>
     <!-- https://github.com/sendgrid/email-templates/blob/master/paste-templates/email-confirmation.html -->
     <meta charset="utf-8" />
     <meta http-equiv="x-ua-compatible" content="ie=edge" />
-    <title>Info Session Confirmation</title>
+    <title>{{block "title" .}}Info Session Confirmation{{end}}</title>
     <meta name="viewport" content="width=device-width, initial-scale=1" />
     <style type="text/css">
       /**
@@ -130,7 +131,7 @@ const InfoSessionHtml = `<!DOCTYPE html>
         opacity: 0;
       "
     >
-      Thanks for signing up for an upcoming info session.
+      {{block "preheader" .}}Thanks for signing up for an upcoming info session.{{end}}
     </div>
     <!-- end preheader -->
 
@@ -258,7 +259,7 @@ const InfoSessionHtml = `<!DOCTYPE html>
                   line-height: 24px;
                 "
               >
-                <p>Hi {{.DisplayName}},</p>
+                {{block "content" .}}<p>Hi {{.DisplayName}},</p>
 
                 {{ if eq .SessionDate "" }}
 
@@ -295,7 +296,7 @@ const InfoSessionHtml = `<!DOCTYPE html>
                 <p>
                   Thank you for your interest and we look forward to meeting
                   soon.
-                </p>
+                </p>{{end}}
               </td>
             </tr>
             <!-- end copy -->
@@ -424,9 +425,9 @@ const InfoSessionHtml = `<!DOCTYPE html>
                 "
               >
                 <p style="margin: 0">
-                  You received this email because we received a request to join
+                  {{block "permission" .}}You received this email because we received a request to join
                   an info session. If you didn't request to join an info session
-                  you can safely delete this email.
+                  you can safely delete this email.{{end}}
                 </p>
               </td>
             </tr>
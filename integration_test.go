@@ -0,0 +1,485 @@
+package signups
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/operationspark/slack-session-signups/email"
+	"github.com/operationspark/slack-session-signups/email/mailguntest"
+	"github.com/operationspark/slack-session-signups/greenlighttest"
+	"github.com/operationspark/slack-session-signups/slack/slacktest"
+)
+
+// integrationEnv runs HandleSignUp against fake Greenlight, Slack, and Mailgun servers.
+type integrationEnv struct {
+	greenlight *greenlighttest.Server
+	slack      *slacktest.Server
+	mailgun    *mailguntest.Server
+}
+
+// newIntegrationEnv starts the fakes and points the service at them for the duration of the test.
+// Slack messages are posted with the Web API, Info Session signups to C0SIGNUPS and workshop signups to C0WORKSHOPS.
+func newIntegrationEnv(t *testing.T) *integrationEnv {
+	env := &integrationEnv{
+		greenlight: greenlighttest.NewServer(),
+		slack:      slacktest.NewServer(),
+		mailgun:    mailguntest.NewServer(),
+	}
+	t.Cleanup(env.greenlight.Close)
+	t.Cleanup(env.slack.Close)
+	t.Cleanup(env.mailgun.Close)
+
+	t.Setenv("GREENLIGHT_WEBHOOK_URL", env.greenlight.WebhookURL())
+	t.Setenv("WORKSHOP_GREENLIGHT_WEBHOOK_URL", env.greenlight.URL+"/workshop/signup")
+	t.Setenv("SLACK_CHANNEL_ID", "C0SIGNUPS")
+	t.Setenv("WORKSHOP_SLACK_CHANNEL_ID", "C0WORKSHOPS")
+	t.Setenv("MAIL_DOMAIN", "mail.operationspark.org")
+	t.Setenv("MAIL_GUN_PRIVATE_API_KEY", "key-123")
+	t.Setenv("MAILGUN_API_BASE", env.mailgun.APIURL())
+	t.Setenv("DISABLE_GREENLIGHT", "")
+	t.Setenv("DISABLE_SLACK", "")
+	t.Cleanup(setVar(&SLACK_WEBHOOK_URL, env.slack.WebhookURL("signups")))
+	t.Cleanup(setVar(&SLACK_BOT_TOKEN, "xoxb-test"))
+	t.Cleanup(setVar(&SLACK_API_URL, env.slack.APIURL()))
+	t.Cleanup(setVar(&SLACK_ROUTES, ""))
+	t.Cleanup(setVar(&DUPLICATE_EMAIL_WINDOW, ""))
+	t.Cleanup(setVar(&DOWNSTREAM_TIMEOUT, "200ms"))
+	t.Cleanup(setVar(&UNSUBSCRIBE_URL, "https://signups.example.com/unsubscribe"))
+	t.Cleanup(setVar(&UNSUBSCRIBE_SECRET, "unsubscribe-secret"))
+	t.Cleanup(setStore(NewMemoryStore()))
+	t.Cleanup(setChecker(email.NewChecker(mxResolver{"email.com": {{Host: "mx.email.com.", Pref: 10}}})))
+	return env
+}
+
+// submit sends the signup body to HandleSignUp and returns the response.
+func (env *integrationEnv) submit(t *testing.T, contentType, body string) (int, SignupResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	HandleSignUp(w, req)
+
+	var resp SignupResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("want a JSON response, got %d %q", w.Code, w.Body)
+	}
+	return w.Code, resp
+}
+
+// statuses returns each delivery's target and status, e.g. "greenlight delivered".
+func statuses(resp SignupResponse) []string {
+	s := []string{}
+	for _, d := range resp.Deliveries {
+		s = append(s, d.Target+" "+d.Status)
+	}
+	return s
+}
+
+const henriJSON = `{
+	"nameFirst": "Henri",
+	"nameLast": "Testaroni",
+	"email": "henri@email.com",
+	"cell": "555-123-4567",
+	"referrer": "instagram",
+	"referrerResponse": "",
+	"startDateTime": "2022-03-14T17:00:00Z",
+	"cohort": "is-mar-14-22-12pm",
+	"sessionId": "WpkB3jcw6gCw2uEMf"
+}`
+
+func TestIntegrationJSONSignup(t *testing.T) {
+	env := newIntegrationEnv(t)
+
+	status, resp := env.submit(t, "application/json", henriJSON)
+	if status != http.StatusOK {
+		t.Fatalf("want status %d, got %d: %+v", http.StatusOK, status, resp)
+	}
+	if diff := cmp.Diff([]string{"greenlight delivered", "slack delivered", "email delivered"}, statuses(resp)); diff != "" {
+		t.Errorf("deliveries mismatch (-want +got):\n%s", diff)
+	}
+
+	// Greenlight received the signup, its referral channel, and (no) history
+	reqs := env.greenlight.Requests()
+	if len(reqs) != 1 || reqs[0].Path != "/signup" || reqs[0].ContentType != "application/json" {
+		t.Fatalf("want 1 JSON post to /signup, got %+v", reqs)
+	}
+	var posted map[string]interface{}
+	if err := json.Unmarshal(reqs[0].Body, &posted); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"programId":        "",
+		"nameFirst":        "Henri",
+		"nameLast":         "Testaroni",
+		"email":            "henri@email.com",
+		"cell":             "555-123-4567",
+		"referrer":         "instagram",
+		"referrerResponse": "",
+		"startDateTime":    "2022-03-14T17:00:00Z",
+		"cohort":           "is-mar-14-22-12pm",
+		"sessionId":        "WpkB3jcw6gCw2uEMf",
+		"token":            "",
+		"referralChannel":  "instagram",
+		"previousSignups":  []interface{}{},
+	}
+	if diff := cmp.Diff(want, posted); diff != "" {
+		t.Errorf("Greenlight body mismatch (-want +got):\n%s", diff)
+	}
+
+	// Slack received one message in #signups, posted with the bot token
+	calls := env.slack.Calls()
+	if len(calls) != 1 {
+		t.Fatalf("want 1 Slack call, got %+v", calls)
+	}
+	c := calls[0]
+	wantText := "Henri Testaroni has signed up for is-mar-14-22-12pm.\nPh: 555-123-4567\nemail: henri@email.com"
+	if c.Method != "chat.postMessage" || c.Channel != "C0SIGNUPS" || c.Token != "xoxb-test" || c.Text != wantText {
+		t.Errorf("unexpected Slack call: %+v", c)
+	}
+
+	// Mailgun sent one welcome email
+	msgs := env.mailgun.Messages()
+	if len(msgs) != 1 {
+		t.Fatalf("want 1 email, got %+v", msgs)
+	}
+	m := msgs[0]
+	if m.Domain != "mail.operationspark.org" || m.From != "Operation Spark <admissions@mail.operationspark.org>" || m.Subject != "Welcome from Operation Spark!" {
+		t.Errorf("unexpected email: %s from %s, subject %q", m.Domain, m.From, m.Subject)
+	}
+	if diff := cmp.Diff([]string{"henri@email.com"}, m.To); diff != "" {
+		t.Errorf("email recipients mismatch (-want +got):\n%s", diff)
+	}
+	if !strings.HasPrefix(m.Headers["List-Unsubscribe"], "<https://signups.example.com/unsubscribe?email=henri%40email.com&token=") {
+		t.Errorf("want a List-Unsubscribe header, got %v", m.Headers)
+	}
+	if d := resp.Deliveries[2]; "<"+d.MessageId+">" != m.Id {
+		t.Errorf("want the email delivery's message ID %s, got %q", m.Id, d.MessageId)
+	}
+	if !strings.Contains(m.HTML, "Henri") || !strings.Contains(m.HTML, "Monday, Mar 14") {
+		t.Errorf("want the welcome email addressed to Henri with the session date")
+	}
+
+	// The signup was stored with its deliveries and Slack message
+	rec, err := signupStore.Get(context.Background(), resp.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.SlackMessages) != 1 || rec.SlackMessages[0].TS != c.TS || string(rec.RawPayload) != henriJSON {
+		t.Errorf("unexpected stored signup: %+v", rec)
+	}
+}
+
+func TestIntegrationFormSignup(t *testing.T) {
+	env := newIntegrationEnv(t)
+
+	form := url.Values{
+		"programId": {"workshop"},
+		"nameFirst": {"Solána"},
+		"nameLast":  {"Rowe"},
+		"email":     {"solana@email.com"},
+		"cell":      {"555-987-6543"},
+		"referrer":  {"Word of mouth"},
+		"cohort":    {"ws-mar-12-22-10am"},
+		"sessionId": {"ws1"},
+	}
+	status, resp := env.submit(t, "application/x-www-form-urlencoded", form.Encode())
+	if status != http.StatusOK {
+		t.Fatalf("want status %d, got %d: %+v", http.StatusOK, status, resp)
+	}
+
+	reqs := env.greenlight.Requests()
+	if len(reqs) != 1 || reqs[0].Path != "/workshop/signup" || !strings.Contains(string(reqs[0].Body), `"nameFirst":"Solána"`) {
+		t.Errorf("want 1 post to the workshop webhook, got %+v", reqs)
+	}
+	calls := env.slack.Calls()
+	if len(calls) != 1 || calls[0].Channel != "C0WORKSHOPS" || !strings.HasPrefix(calls[0].Text, "Solána Rowe") {
+		t.Errorf("want 1 message in the workshops channel, got %+v", calls)
+	}
+	msgs := env.mailgun.Messages()
+	if len(msgs) != 1 || msgs[0].Subject != "Welcome to your Operation Spark Workshop!" || msgs[0].To[0] != "solana@email.com" {
+		t.Errorf("want the workshop welcome email, got %+v", msgs)
+	}
+}
+
+func TestIntegrationSharedPhone(t *testing.T) {
+	env := newIntegrationEnv(t)
+	defer setVar(&DUPLICATE_EMAIL_WINDOW, "72h")()
+
+	signups := []struct {
+		name  string
+		body  string
+		email string
+	}{
+		{"first signup", henriJSON, "email delivered"},
+		// A family member with the same phone number is still welcomed
+		{"same phone", strings.Replace(henriJSON, "henri@email.com", "solana@email.com", 1), "email delivered"},
+		{"same email", henriJSON, "email suppressed"},
+	}
+	for _, s := range signups {
+		status, resp := env.submit(t, "application/json", s.body)
+		if status != http.StatusOK {
+			t.Fatalf("%s: want status %d, got %d: %+v", s.name, http.StatusOK, status, resp)
+		}
+		if got := statuses(resp)[2]; got != s.email {
+			t.Errorf("%s: want %q, got %q", s.name, s.email, got)
+		}
+	}
+	if n := len(env.mailgun.Messages()); n != 2 {
+		t.Errorf("want 2 welcome emails sent, got %d", n)
+	}
+}
+
+func TestIntegrationUnknownProgram(t *testing.T) {
+	env := newIntegrationEnv(t)
+
+	body := strings.Replace(henriJSON, `"nameFirst"`, `"programId": "bootcamp", "nameFirst"`, 1)
+	status, resp := env.submit(t, "application/json", body)
+	if status != http.StatusOK {
+		t.Fatalf("want status %d, got %d: %+v", http.StatusOK, status, resp)
+	}
+
+	// The program is passed on and stored as received, and the signup is treated as an Info Session
+	var posted Signup
+	if reqs := env.greenlight.Requests(); len(reqs) != 1 || json.Unmarshal(reqs[0].Body, &posted) != nil || posted.ProgramId != "bootcamp" {
+		t.Errorf("want Greenlight sent programId bootcamp, got %+v", reqs)
+	}
+	rec, err := signupStore.Get(context.Background(), resp.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Signup.ProgramId != "bootcamp" || string(rec.RawPayload) != body {
+		t.Errorf("want the signup stored as received, got programId %q and payload %s", rec.Signup.ProgramId, rec.RawPayload)
+	}
+	if calls := env.slack.Calls(); len(calls) != 1 || !strings.Contains(string(calls[0].Body), "C0SIGNUPS") {
+		t.Errorf("want the signup posted to the Info Session channel, got %v", calls)
+	}
+}
+
+func TestIntegrationMistypedEmail(t *testing.T) {
+	env := newIntegrationEnv(t)
+
+	body := strings.Replace(henriJSON, "henri@email.com", "henri@gmial.con", 1)
+	status, resp := env.submit(t, "application/json", body)
+	if status != http.StatusOK || resp.DidYouMean != "henri@gmail.com" {
+		t.Errorf("want status %d with a suggestion, got %d %+v", http.StatusOK, status, resp)
+	}
+	// gmial.con doesn't exist, but the check can be wrong, so the welcome email is still sent
+	if diff := cmp.Diff([]string{"greenlight delivered", "slack delivered", "email delivered"}, statuses(resp)); diff != "" {
+		t.Errorf("deliveries mismatch (-want +got):\n%s", diff)
+	}
+	if len(env.mailgun.Messages()) != 1 {
+		t.Errorf("want the welcome email sent anyway, got %d", len(env.mailgun.Messages()))
+	}
+	calls := env.slack.Calls()
+	if len(calls) != 1 || !strings.Contains(string(calls[0].Body), ":warning: Email may be mistyped. Did they mean henri@gmail.com?") {
+		t.Errorf("want a warning in the Slack message, got %+v", calls)
+	}
+}
+
+func TestIntegrationInvalidSignup(t *testing.T) {
+	env := newIntegrationEnv(t)
+
+	status, resp := env.submit(t, "application/json", `{"nameFirst": "Henri", "email": "henri@email.com", "startDateTime": "next tuesday"}`)
+	if status != http.StatusBadRequest || resp.Field != "startDateTime" {
+		t.Errorf("want status %d for startDateTime, got %d %+v", http.StatusBadRequest, status, resp)
+	}
+	if len(env.greenlight.Requests()) != 0 || len(env.slack.Calls()) != 0 || len(env.mailgun.Messages()) != 0 {
+		t.Error("want nothing sent downstream for an invalid signup")
+	}
+}
+
+func TestIntegrationReplay(t *testing.T) {
+	env := newIntegrationEnv(t)
+	// Signups are also posted to an outreach webhook, which is down
+	outreach := slacktest.NewServer()
+	defer outreach.Close()
+	outreach.FailWith(serverError)
+	defer setVar(&SLACK_ROUTES, `[{"name": "all", "destinations": ["C0SIGNUPS", "`+outreach.WebhookURL("outreach")+`"]}]`)()
+
+	status, resp := env.submit(t, "application/json", henriJSON)
+	if status != http.StatusInternalServerError {
+		t.Fatalf("want status %d, got %d: %+v", http.StatusInternalServerError, status, resp)
+	}
+	// Henri unsubscribed before the signup was replayed
+	if err := suppress(context.Background(), "henri@email.com", "unsubscribed"); err != nil {
+		t.Fatal(err)
+	}
+
+	outreach.FailWith(nil)
+	results, err := Replay(context.Background(), ReplayOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, r := range results {
+		got = append(got, r.Target+" "+r.Result)
+	}
+	if diff := cmp.Diff([]string{"greenlight skipped", "slack delivered", "email suppressed"}, got); diff != "" {
+		t.Errorf("replay mismatch (-want +got):\n%s", diff)
+	}
+	// Only the failed destination is posted to again, and the suppressed address isn't emailed
+	if channel, webhook := len(env.slack.Calls()), len(outreach.Calls()); channel != 1 || webhook != 2 {
+		t.Errorf("want 1 channel post and 2 webhook posts, got %d and %d", channel, webhook)
+	}
+	if len(env.mailgun.Messages()) != 0 {
+		t.Errorf("want no email sent to a suppressed address, got %d", len(env.mailgun.Messages()))
+	}
+}
+
+// Failures injected into the fake downstreams
+var (
+	serverError = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	})
+	// hang responds after the client gives up (DOWNSTREAM_TIMEOUT)
+	hang = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+	malformed = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `<html><body>502 Bad Gateway</body></html>`)
+	})
+	slackError = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"ok": false, "error": "channel_not_found"}`)
+	})
+)
+
+func TestIntegrationFailures(t *testing.T) {
+	tests := []struct {
+		name   string
+		inject func(env *integrationEnv)
+		// wantStatus is HandleSignUp's response status. Email failures don't fail the signup.
+		wantStatus     int
+		wantDeliveries []string
+		wantError      string
+		// Requests each downstream received, including failed ones
+		wantGreenlight, wantSlack, wantEmails int
+	}{
+		{
+			name:           "Greenlight 500",
+			inject:         func(env *integrationEnv) { env.greenlight.FailWith(serverError) },
+			wantStatus:     http.StatusInternalServerError,
+			wantDeliveries: []string{"greenlight failed"},
+			wantError:      "500 Internal Server Error",
+			wantGreenlight: 1,
+		},
+		{
+			name:           "Greenlight timeout",
+			inject:         func(env *integrationEnv) { env.greenlight.FailWith(hang) },
+			wantStatus:     http.StatusInternalServerError,
+			wantDeliveries: []string{"greenlight failed"},
+			wantError:      "context deadline exceeded",
+			wantGreenlight: 1,
+		},
+		{
+			// Greenlight's response body isn't read, so any 2xx is a delivery
+			name:           "Greenlight malformed response",
+			inject:         func(env *integrationEnv) { env.greenlight.FailWith(malformed) },
+			wantStatus:     http.StatusOK,
+			wantDeliveries: []string{"greenlight delivered", "slack delivered", "email delivered"},
+			wantGreenlight: 1, wantSlack: 1, wantEmails: 1,
+		},
+		{
+			name:           "Slack 500",
+			inject:         func(env *integrationEnv) { env.slack.FailWith(serverError) },
+			wantStatus:     http.StatusInternalServerError,
+			wantDeliveries: []string{"greenlight delivered", "slack failed"},
+			wantError:      "error sending Slack webhook: error calling Slack chat.postMessage: 500 Internal Server Error",
+			wantGreenlight: 1, wantSlack: 1,
+		},
+		{
+			name:           "Slack timeout",
+			inject:         func(env *integrationEnv) { env.slack.FailWith(hang) },
+			wantStatus:     http.StatusInternalServerError,
+			wantDeliveries: []string{"greenlight delivered", "slack failed"},
+			wantError:      "context deadline exceeded",
+			wantGreenlight: 1, wantSlack: 1,
+		},
+		{
+			name:           "Slack malformed response",
+			inject:         func(env *integrationEnv) { env.slack.FailWith(malformed) },
+			wantStatus:     http.StatusInternalServerError,
+			wantDeliveries: []string{"greenlight delivered", "slack failed"},
+			wantError:      "error reading Slack chat.postMessage response",
+			wantGreenlight: 1, wantSlack: 1,
+		},
+		{
+			name:           "Slack API error",
+			inject:         func(env *integrationEnv) { env.slack.FailWith(slackError) },
+			wantStatus:     http.StatusInternalServerError,
+			wantDeliveries: []string{"greenlight delivered", "slack failed"},
+			wantError:      "channel_not_found",
+			wantGreenlight: 1, wantSlack: 1,
+		},
+		{
+			name:           "Mailgun 500",
+			inject:         func(env *integrationEnv) { env.mailgun.FailWith(serverError) },
+			wantStatus:     http.StatusOK,
+			wantDeliveries: []string{"greenlight delivered", "slack delivered", "email failed"},
+			wantError:      "500",
+			wantGreenlight: 1, wantSlack: 1, wantEmails: 1,
+		},
+		{
+			name:           "Mailgun timeout",
+			inject:         func(env *integrationEnv) { env.mailgun.FailWith(hang) },
+			wantStatus:     http.StatusOK,
+			wantDeliveries: []string{"greenlight delivered", "slack delivered", "email failed"},
+			wantError:      "context deadline exceeded",
+			wantGreenlight: 1, wantSlack: 1, wantEmails: 1,
+		},
+		{
+			name:           "Mailgun malformed response",
+			inject:         func(env *integrationEnv) { env.mailgun.FailWith(malformed) },
+			wantStatus:     http.StatusOK,
+			wantDeliveries: []string{"greenlight delivered", "slack delivered", "email failed"},
+			wantGreenlight: 1, wantSlack: 1, wantEmails: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			env := newIntegrationEnv(t)
+			test.inject(env)
+
+			status, resp := env.submit(t, "application/json", henriJSON)
+			if status != test.wantStatus {
+				t.Errorf("want status %d, got %d", test.wantStatus, status)
+			}
+			if diff := cmp.Diff(test.wantDeliveries, statuses(resp)); diff != "" {
+				t.Errorf("deliveries mismatch (-want +got):\n%s", diff)
+			}
+			errs := resp.Error
+			for _, d := range resp.Deliveries {
+				errs += " " + d.Error
+			}
+			if !strings.Contains(errs, test.wantError) {
+				t.Errorf("want error containing %q, got %q", test.wantError, errs)
+			}
+			got := []int{len(env.greenlight.Requests()), len(env.slack.Calls()), len(env.mailgun.Messages())}
+			if diff := cmp.Diff([]int{test.wantGreenlight, test.wantSlack, test.wantEmails}, got); diff != "" {
+				t.Errorf("downstream requests (Greenlight, Slack, Mailgun) mismatch (-want +got):\n%s", diff)
+			}
+
+			// Every attempt is stored, so failed signups can be replayed
+			rec, err := signupStore.Get(context.Background(), resp.Id)
+			if err != nil {
+				t.Fatalf("want the signup stored: %s", err)
+			}
+			if len(rec.Deliveries) != len(test.wantDeliveries) {
+				t.Errorf("want %d stored deliveries, got %+v", len(test.wantDeliveries), rec.Deliveries)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package signups
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/operationspark/slack-session-signups/slack"
+)
+
+// SLACK_SIGNING_SECRET verifies that interactions and slash commands were sent by the OS Signups Slack App.
+var SLACK_SIGNING_SECRET = os.Getenv("SLACK_SIGNING_SECRET")
+
+// interactionPayload is the part of a Slack block_actions payload this service uses.
+// https://api.slack.com/reference/interaction-payloads/block-actions
+type interactionPayload struct {
+	Type string `json:"type"`
+	User struct {
+		Id       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"user"`
+	Container struct {
+		ChannelId string `json:"channel_id"`
+		MessageTs string `json:"message_ts"`
+	} `json:"container"`
+	ResponseURL string `json:"response_url"`
+	Actions     []struct {
+		ActionId string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+}
+
+// HandleSlackInteraction handles clicks on the buttons of signup messages (Slack block_actions).
+// It performs the action, records who took it, and updates the signup's messages to show it.
+func HandleSlackInteraction(w http.ResponseWriter, r *http.Request) {
+	_, err := slack.VerifyRequest(r, SLACK_SIGNING_SECRET, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var p interactionPayload
+	err = json.Unmarshal([]byte(r.FormValue("payload")), &p)
+	if err != nil {
+		http.Error(w, "Error reading interaction payload", http.StatusBadRequest)
+		return
+	}
+	if p.Type != "block_actions" {
+		// Acknowledge interactions this service doesn't handle
+		return
+	}
+
+	for _, a := range p.Actions {
+		// The action is checked and recorded in one Update, so two quick clicks can't both resend the email
+		rec, err := signupStore.Update(r.Context(), a.Value, func(rec *Record) error {
+			return performAction(r.Context(), rec, a.ActionId, p.User.Id)
+		})
+		if err != nil {
+			fmt.Printf("error performing Slack action %s for signup %q: %s\n", a.ActionId, a.Value, err)
+			continue
+		}
+
+		if a.ActionId == actionResendWelcome {
+			// Cloud Functions can stop running once the click is acknowledged, so the email is sent first
+			rec, err = resendWelcome(r.Context(), rec)
+			if err != nil {
+				fmt.Printf("error saving welcome email resend for signup %s %s\n", a.Value, err)
+				continue
+			}
+		}
+
+		err = updateSignupMessages(r.Context(), rec, p)
+		if err != nil {
+			fmt.Printf("error updating Slack messages for signup %s %s\n", rec.Id, err)
+		}
+	}
+}
+
+// resendInterval is how long after a welcome email is resent the "Resend welcome email" button is ignored, so double clicks don't send it twice.
+const resendInterval = 10 * time.Minute
+
+// resendTimeout is how long resending a welcome email can take. Slack shows the user an error if the click isn't acknowledged within 3 seconds.
+const resendTimeout = 2 * time.Second
+
+// errResentRecently is returned for a resend within the resendInterval of the last one.
+var errResentRecently = errors.New("the welcome email was resent recently")
+
+// resendWelcome sends the welcome email for the resend recorded as the record's latest activity, and records the result.
+// It's recorded like the first email, so Mailgun's events for it are matched to the signup.
+func resendWelcome(ctx context.Context, rec *Record) (*Record, error) {
+	resend := rec.Activity[len(rec.Activity)-1]
+	sendCtx, cancel := context.WithTimeout(ctx, resendTimeout)
+	messageId, sendErr := rec.Signup.sendWelcome(sendCtx, emailFollowUp)
+	cancel()
+
+	return signupStore.Update(ctx, rec.Id, func(rec *Record) error {
+		if sendErr == nil {
+			rec.recordEmail(messageId, nil)
+			return nil
+		}
+		// Nothing is sent to suppressed addresses, so there's no delivery to record
+		if !errors.Is(sendErr, ErrSuppressed) {
+			rec.recordEmail(messageId, sendErr)
+		}
+		for i := range rec.Activity {
+			if a := rec.Activity[i]; a.Action == resend.Action && a.UserId == resend.UserId && a.At.Equal(resend.At) {
+				rec.Activity[i].Action = "tried to resend the welcome email"
+				rec.Activity[i].Note = sendErr.Error()
+			}
+		}
+		return nil
+	})
+}
+
+// resentRecently reports whether the welcome email was resent within the resendInterval.
+func (r *Record) resentRecently(now time.Time) bool {
+	for _, a := range r.Activity {
+		if a.Action == "resent the welcome email" && now.Sub(a.At) < resendInterval {
+			return true
+		}
+	}
+	return false
+}
+
+// performAction performs a button's action on the signup record on behalf of the Slack user.
+func performAction(ctx context.Context, rec *Record, actionId, userId string) error {
+	a := Activity{UserId: userId, At: time.Now().UTC()}
+	switch actionId {
+	case actionMarkContacted:
+		a.Action = "marked contacted"
+
+	case actionAssignToMe:
+		// The message shows who the signup is assigned to, so the assignment isn't added to the activity
+		rec.AssignedTo = userId
+		return nil
+
+	case actionResendWelcome:
+		// The email is sent by resendWelcome once the resend is recorded
+		if rec.resentRecently(a.At) {
+			return errResentRecently
+		}
+		a.Action = "resent the welcome email"
+
+	default:
+		return fmt.Errorf("unknown action: '%s'", actionId)
+	}
+	rec.Activity = append(rec.Activity, a)
+	return nil
+}
+
+// updateSignupMessages replaces the message the interaction came from, and any other Web API messages about the signup, with the current record.
+func updateSignupMessages(ctx context.Context, rec *Record, p interactionPayload) error {
+	msg := signupMessage(rec)
+	err := slack.Respond(ctx, p.ResponseURL, msg)
+	if err != nil {
+		return err
+	}
+
+	client := slackClient()
+	if client == nil {
+		return nil
+	}
+	for _, ref := range rec.SlackMessages {
+		if ref.Channel == p.Container.ChannelId && ref.TS == p.Container.MessageTs {
+			continue
+		}
+		err := client.UpdateMessage(ctx, ref, msg)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package signups
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/operationspark/slack-session-signups/slack"
+	"github.com/operationspark/slack-session-signups/slack/slacktest"
+)
+
+func TestHandleSlackInteraction(t *testing.T) {
+	srv := slacktest.NewServer()
+	defer srv.Close()
+	defer setVar(&SLACK_SIGNING_SECRET, "signing-secret")()
+	defer setVar(&SLACK_BOT_TOKEN, "")()
+	defer setStore(NewMemoryStore())()
+
+	rec := newRecord(Signup{NameFirst: "Henri", NameLast: "Testaroni", Email: "henri@email.com", Cohort: "is-mar-14-22-12pm"})
+	if err := signupStore.Save(context.Background(), rec); err != nil {
+		t.Fatal(err)
+	}
+	if err := suppress(context.Background(), rec.Signup.Email, SuppressUnsubscribed); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		actionId   string
+		secret     string
+		wantStatus int
+		want       string
+	}{
+		{"invalid signature", actionMarkContacted, "wrong-secret", http.StatusUnauthorized, ""},
+		{"mark contacted", actionMarkContacted, "signing-secret", http.StatusOK, "<@U0STAFF> marked contacted"},
+		{"assign to me", actionAssignToMe, "signing-secret", http.StatusOK, "Assigned to <@U0STAFF>"},
+		{"resend to unsubscribed", actionResendWelcome, "signing-secret", http.StatusOK, "<@U0STAFF> tried to resend the welcome email (email address is suppressed: henri@email.com unsubscribed)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			before := len(srv.Calls())
+			payload := fmt.Sprintf(`{
+				"type": "block_actions",
+				"user": {"id": "U0STAFF", "username": "staff"},
+				"response_url": %q,
+				"actions": [{"action_id": %q, "value": %q}]
+			}`, srv.ResponseURL("1"), test.actionId, rec.Id)
+			req := signedSlackRequest(test.secret, url.Values{"payload": {payload}}.Encode())
+
+			w := httptest.NewRecorder()
+			HandleSlackInteraction(w, req)
+			if w.Code != test.wantStatus {
+				t.Fatalf("want status %d, got %d", test.wantStatus, w.Code)
+			}
+			if test.want == "" {
+				return
+			}
+
+			calls := srv.Calls()
+			if len(calls) <= before || calls[len(calls)-1].Method != "response" {
+				t.Fatalf("want the original message replaced through the response_url, got %+v", calls[before:])
+			}
+			body := strings.NewReplacer(`\u003c`, "<", `\u003e`, ">").Replace(string(calls[len(calls)-1].Body))
+			if !strings.Contains(body, test.want) {
+				t.Errorf("updated message missing %q\n%s", test.want, body)
+			}
+		})
+	}
+
+	got, err := signupStore.Get(context.Background(), rec.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AssignedTo != "U0STAFF" || len(got.Activity) != 2 || len(got.Deliveries) != 0 {
+		t.Errorf("actions not recorded on signup: %+v", got)
+	}
+}
+
+func TestResendWelcomeInterval(t *testing.T) {
+	now := time.Now().UTC()
+	rec := newRecord(Signup{NameFirst: "Henri", Email: "henri@email.com"})
+	rec.Activity = []Activity{{Action: "resent the welcome email", UserId: "U0STAFF", At: now.Add(-time.Minute)}}
+	if err := performAction(context.Background(), rec, actionResendWelcome, "U0OTHER"); err != errResentRecently {
+		t.Errorf("want a resend a minute after the last one ignored, got %v", err)
+	}
+
+	rec.Activity[0].At = now.Add(-resendInterval)
+	if err := performAction(context.Background(), rec, actionResendWelcome, "U0OTHER"); err != nil {
+		t.Errorf("want a resend after the interval, got %v", err)
+	}
+	// The resend is recorded right away, so a second click while it's being sent is ignored
+	if err := performAction(context.Background(), rec, actionResendWelcome, "U0OTHER"); err != errResentRecently {
+		t.Errorf("want a second click ignored, got %v", err)
+	}
+}
+
+func TestResendWelcomeClickedTwice(t *testing.T) {
+	env := newIntegrationEnv(t)
+	defer setVar(&SLACK_SIGNING_SECRET, "signing-secret")()
+
+	rec := newRecord(Signup{NameFirst: "Henri", NameLast: "Testaroni", Email: "henri@email.com", Cohort: "is-mar-14-22-12pm"})
+	if err := signupStore.Save(context.Background(), rec); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both clicks arrive before either is acknowledged
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			payload := fmt.Sprintf(`{
+				"type": "block_actions",
+				"user": {"id": "U0STAFF", "username": "staff"},
+				"response_url": %q,
+				"actions": [{"action_id": %q, "value": %q}]
+			}`, env.slack.ResponseURL("1"), actionResendWelcome, rec.Id)
+			w := httptest.NewRecorder()
+			HandleSlackInteraction(w, signedSlackRequest("signing-secret", url.Values{"payload": {payload}}.Encode()))
+		}()
+	}
+	wg.Wait()
+
+	// The email is sent before the click is acknowledged, and only once
+	if n := len(env.mailgun.Messages()); n != 1 {
+		t.Errorf("want 1 welcome email resent, got %d", n)
+	}
+	got, err := signupStore.Get(context.Background(), rec.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Activity) != 1 || got.Activity[0].Action != "resent the welcome email" || got.Status(TargetEmail) != StatusDelivered {
+		t.Errorf("want one resend recorded, got activity %+v and deliveries %+v", got.Activity, got.Deliveries)
+	}
+}
+
+// signedSlackRequest creates a form-encoded request signed the way Slack signs interaction and slash command requests.
+func signedSlackRequest(secret, body string) *http.Request {
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req := httptest.NewRequest(http.MethodPost, "/slack", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Slack-Request-Timestamp", ts)
+	req.Header.Set("X-Slack-Signature", slack.Sign(secret, ts, []byte(body)))
+	return req
+}
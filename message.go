@@ -0,0 +1,56 @@
+package signups
+
+import (
+	"fmt"
+
+	"github.com/operationspark/slack-session-signups/slack"
+)
+
+// Slack action IDs for the buttons on signup messages.
+const (
+	actionMarkContacted = "mark_contacted"
+	actionAssignToMe    = "assign_to_me"
+	actionResendWelcome = "resend_welcome"
+)
+
+// signupMessage creates the Slack message for a signup record: the signup summary, what has happened since, and buttons for staff to follow up.
+func signupMessage(rec *Record) slack.Message {
+	summary := rec.Signup.Summary()
+	blocks := []slack.Block{slack.Section(summary)}
+
+	notes := []string{}
+	if note := repeatNote(rec.History); note != "" {
+		notes = append(notes, note)
+	}
+	for _, warning := range rec.EmailCheck.Warnings() {
+		notes = append(notes, ":warning: "+warning)
+	}
+	if rec.AssignedTo != "" {
+		notes = append(notes, fmt.Sprintf("Assigned to <@%s>", rec.AssignedTo))
+	}
+	for _, a := range rec.Activity {
+		notes = append(notes, a.String())
+	}
+	if len(notes) > 0 {
+		blocks = append(blocks, slack.Context(notes...))
+	}
+
+	blocks = append(blocks, slack.Actions("signup",
+		slack.Button(actionMarkContacted, "Mark contacted", rec.Id),
+		slack.Button(actionAssignToMe, "Assign to me", rec.Id),
+		slack.Button(actionResendWelcome, "Resend welcome email", rec.Id),
+	))
+	return slack.Message{Text: summary, Blocks: blocks}
+}
+
+// String describes the activity for Slack, e.g. "<@U123> marked contacted" or "Status: cancelled (schedule conflict)".
+func (a Activity) String() string {
+	s := "Status: " + a.Action
+	if a.UserId != "" {
+		s = fmt.Sprintf("<@%s> %s", a.UserId, a.Action)
+	}
+	if a.Note != "" {
+		s += fmt.Sprintf(" (%s)", a.Note)
+	}
+	return s
+}
@@ -0,0 +1,28 @@
+package signups
+
+import (
+	_ "embed"
+	"fmt"
+	"net/http"
+)
+
+// openAPISpec is the OpenAPI 3 document for the signup API. The contract tests check it against Signup and SignupResponse,
+// so update it with them.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// HandleOpenAPI serves the signup API's OpenAPI 3 document.
+func HandleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	// Anyone can read the spec, e.g. from an API docs page on another site
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	_, err := w.Write(openAPISpec)
+	if err != nil {
+		fmt.Printf("error writing OpenAPI spec %s\n", err.Error())
+	}
+}
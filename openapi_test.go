@@ -0,0 +1,268 @@
+package signups
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// openAPIDoc is the part of the OpenAPI document the contract tests check.
+type openAPIDoc struct {
+	OpenAPI string `json:"openapi"`
+	Paths   map[string]map[string]struct {
+		Deprecated bool                       `json:"deprecated"`
+		Responses  map[string]json.RawMessage `json:"responses"`
+	} `json:"paths"`
+	Components struct {
+		Schemas map[string]*openAPISchema `json:"schemas"`
+	} `json:"components"`
+}
+
+type openAPISchema struct {
+	Ref        string                    `json:"$ref"`
+	Type       string                    `json:"type"`
+	Format     string                    `json:"format"`
+	Required   []string                  `json:"required"`
+	Enum       []string                  `json:"enum"`
+	Items      *openAPISchema            `json:"items"`
+	Properties map[string]*openAPISchema `json:"properties"`
+}
+
+// schemaShape is the part of a property's schema that must match its Go type.
+type schemaShape struct {
+	Type, Format, Ref string
+	Items             *schemaShape
+}
+
+func loadOpenAPI(t *testing.T) openAPIDoc {
+	t.Helper()
+	var doc openAPIDoc
+	if err := json.Unmarshal(openAPISpec, &doc); err != nil {
+		t.Fatalf("openapi.json isn't valid JSON: %v", err)
+	}
+	return doc
+}
+
+// specShape reduces a property's schema to its shape. Formats other than date-time (e.g. email) are documentation.
+func specShape(s *openAPISchema) *schemaShape {
+	if s == nil {
+		return nil
+	}
+	shape := &schemaShape{Type: s.Type, Ref: s.Ref, Items: specShape(s.Items)}
+	if s.Format == "date-time" {
+		shape.Format = s.Format
+	}
+	return shape
+}
+
+// goShape is the schema shape a Go type is encoded as.
+func goShape(t reflect.Type) *schemaShape {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == reflect.TypeOf(time.Time{}):
+		return &schemaShape{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.String:
+		return &schemaShape{Type: "string"}
+	case t.Kind() == reflect.Bool:
+		return &schemaShape{Type: "boolean"}
+	case t.Kind() == reflect.Int:
+		return &schemaShape{Type: "integer"}
+	case t.Kind() == reflect.Slice:
+		return &schemaShape{Type: "array", Items: goShape(t.Elem())}
+	case t.Kind() == reflect.Struct:
+		return &schemaShape{Ref: "#/components/schemas/" + t.Name()}
+	}
+	return &schemaShape{Type: t.Kind().String()}
+}
+
+// jsonFields maps a struct's JSON field names to their Go fields.
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = t.Field(i).Name
+		}
+		if name != "-" {
+			fields[name] = t.Field(i)
+		}
+	}
+	return fields
+}
+
+func TestOpenAPISchemas(t *testing.T) {
+	doc := loadOpenAPI(t)
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("want OpenAPI 3.0.3, got %q", doc.OpenAPI)
+	}
+
+	types := map[string]reflect.Type{
+		"Signup":            reflect.TypeOf(Signup{}),
+		"SignupResponse":    reflect.TypeOf(SignupResponse{}),
+		"Delivery":          reflect.TypeOf(Delivery{}),
+		"InvalidFieldError": reflect.TypeOf(InvalidFieldError{}),
+	}
+	for name, typ := range types {
+		schema, ok := doc.Components.Schemas[name]
+		if !ok {
+			t.Errorf("openapi.json has no %s schema", name)
+			continue
+		}
+		want := map[string]*schemaShape{}
+		for field, f := range jsonFields(typ) {
+			want[field] = goShape(f.Type)
+		}
+		got := map[string]*schemaShape{}
+		for field, s := range schema.Properties {
+			got[field] = specShape(s)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("%s schema doesn't match the Go type (-Go +openapi.json):\n%s", name, diff)
+		}
+	}
+
+	// Every referenced schema is in the spec
+	for name, schema := range doc.Components.Schemas {
+		for field, s := range schema.Properties {
+			for _, ref := range []string{s.Ref, specShape(s).itemsRef()} {
+				if ref != "" && doc.Components.Schemas[strings.TrimPrefix(ref, "#/components/schemas/")] == nil {
+					t.Errorf("%s.%s refers to a missing schema %s", name, field, ref)
+				}
+			}
+		}
+	}
+}
+
+func (s *schemaShape) itemsRef() string {
+	if s.Items == nil {
+		return ""
+	}
+	return s.Items.Ref
+}
+
+func TestOpenAPISignupFields(t *testing.T) {
+	doc := loadOpenAPI(t)
+	// Every field is optional, as HandleSignUp doesn't reject signups with missing fields
+	if required := doc.Components.Schemas["Signup"].Required; len(required) != 0 {
+		t.Errorf("want no required Signup fields, got %v", required)
+	}
+
+	fields := jsonFields(reflect.TypeOf(Signup{}))
+	// Form submissions use the same schema, so form field names must match the JSON names
+	for name, f := range fields {
+		if form := f.Tag.Get("schema"); form != name {
+			t.Errorf("Signup.%s form field %q doesn't match its JSON name %q", f.Name, form, name)
+		}
+	}
+}
+
+func TestOpenAPIEnums(t *testing.T) {
+	delivery := loadOpenAPI(t).Components.Schemas["Delivery"]
+	if diff := cmp.Diff(AllTargets, delivery.Properties["target"].Enum); diff != "" {
+		t.Errorf("Delivery target enum mismatch (-AllTargets +openapi.json):\n%s", diff)
+	}
+	statuses := []string{StatusDelivered, StatusFailed, StatusPending, StatusSuppressed, StatusBounced, StatusComplained}
+	if diff := cmp.Diff(statuses, delivery.Properties["status"].Enum); diff != "" {
+		t.Errorf("Delivery status enum mismatch (-Go +openapi.json):\n%s", diff)
+	}
+}
+
+func TestOpenAPIResponses(t *testing.T) {
+	t.Setenv("DISABLE_GREENLIGHT", "true")
+	t.Setenv("DISABLE_SLACK", "true")
+	defer setVar(&CORS_ALLOWED_ORIGINS, "https://operationspark.org")()
+	defer setVar(&SIGNUP_THANK_YOU_URL, "https://operationspark.org/thank-you")()
+	defer setStore(NewMemoryStore())()
+	doc := loadOpenAPI(t)
+
+	legacy, v1 := doc.Paths["/"]["post"], doc.Paths["/v1/signups"]["post"]
+	if !legacy.Deprecated || v1.Deprecated {
+		t.Errorf("want only the legacy root path deprecated")
+	}
+	if diff := cmp.Diff(v1.Responses, legacy.Responses); diff != "" {
+		t.Errorf("want the same responses from both paths (-v1 +legacy):\n%s", diff)
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		headers     map[string]string
+		body        string
+		wantStatus  int
+	}{
+		{"JSON", "application/json", nil, `{"nameFirst": "Quinta", "nameLast": "Brunson", "email": "quinta@email.com"}`, http.StatusOK},
+		{"form from a browser", "application/x-www-form-urlencoded", map[string]string{"Accept": "text/html"}, "nameFirst=Quinta&nameLast=Brunson&email=quinta@email.com", http.StatusSeeOther},
+		{"invalid", "application/json", nil, `{"nameFirst": "Quinta", "nameLast": "Brunson", "email": "quinta", "startDateTime": "next tuesday"}`, http.StatusBadRequest},
+		{"unknown fields", "application/json", nil, `{"firstName": "Quinta", "lastName": "Brunson"}`, http.StatusBadRequest},
+		{"disallowed origin", "application/json", map[string]string{"Origin": "https://example.com"}, `{}`, http.StatusForbidden},
+		{"too large", "application/json", nil, strings.Repeat(" ", maxSignupBytes+1), http.StatusRequestEntityTooLarge},
+		{"unsupported content type", "text/plain", nil, "Quinta Brunson", http.StatusUnsupportedMediaType},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/signups", strings.NewReader(test.body))
+			req.Header.Set("Content-Type", test.contentType)
+			for k, v := range test.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			HandleSignUp(w, req)
+
+			if w.Code != test.wantStatus {
+				t.Fatalf("want status %d, got %d %s", test.wantStatus, w.Code, w.Body)
+			}
+			if _, ok := v1.Responses[strconv.Itoa(w.Code)]; !ok {
+				t.Errorf("openapi.json doesn't document status %d", w.Code)
+			}
+			if w.Header().Get("Content-Type") == "application/json" {
+				// Responses only have the fields in the SignupResponse schema
+				var body map[string]json.RawMessage
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+					t.Fatalf("want a JSON response, got %q", w.Body)
+				}
+				for field := range body {
+					if doc.Components.Schemas["SignupResponse"].Properties[field] == nil {
+						t.Errorf("response field %s isn't in the SignupResponse schema", field)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestHandleOpenAPI(t *testing.T) {
+	w := httptest.NewRecorder()
+	HandleOpenAPI(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("want the JSON spec, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !bytes.Equal(w.Body.Bytes(), openAPISpec) {
+		t.Error("want the embedded openapi.json")
+	}
+
+	paths := []string{}
+	for path := range loadOpenAPI(t).Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	if diff := cmp.Diff([]string{"/", "/v1/signups"}, paths); diff != "" {
+		t.Errorf("paths mismatch (-want +got):\n%s", diff)
+	}
+
+	w = httptest.NewRecorder()
+	HandleOpenAPI(w, httptest.NewRequest(http.MethodPost, "/openapi.json", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("want %d for POST, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
@@ -0,0 +1,142 @@
+package signups
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// PreviewFixture is a sample Signup used to render email templates during development.
+type PreviewFixture struct {
+	Name   string
+	Signup Signup
+}
+
+// PreviewFixtures returns the sample Signups every email template is previewed (and snapshot tested) with.
+func PreviewFixtures() []PreviewFixture {
+	sessionStart, _ := time.Parse(time.RFC3339, "2022-03-14T17:00:00Z")
+	return []PreviewFixture{
+		{
+			Name: "with-session",
+			Signup: Signup{
+				NameFirst:     "Henri",
+				NameLast:      "Testaroni",
+				Email:         "henri@email.com",
+				Cell:          "555-123-4567",
+				Referrer:      "instagram",
+				StartDateTime: sessionStart,
+				Cohort:        "is-mar-14-22-12pm",
+			},
+		},
+		{
+			Name: "without-session",
+			Signup: Signup{
+				NameFirst: "Amir",
+				NameLast:  "Thompson",
+				Email:     "amir@email.com",
+				Cell:      "555-555-5555",
+			},
+		},
+		{
+			Name: "long-names",
+			Signup: Signup{
+				NameFirst:     "Bartholomew-Maximilian Alexander",
+				NameLast:      "Fitzgerald-Montgomery-Worthington III",
+				Email:         "bartholomew.maximilian.fitzgerald.montgomery@email.com",
+				Cell:          "555-123-4567",
+				StartDateTime: sessionStart,
+				Cohort:        "is-mar-14-22-12pm",
+			},
+		},
+		// Names with accents and ñ, to check they're encoded correctly
+		{
+			Name: "accented-names",
+			Signup: Signup{
+				NameFirst:     "María José",
+				NameLast:      "Núñez Peña",
+				Email:         "mjnunez@email.com",
+				Cell:          "555-987-6543",
+				Referrer:      "Word of mouth",
+				StartDateTime: sessionStart,
+				Cohort:        "is-mar-14-22-12pm",
+			},
+		},
+	}
+}
+
+var previewIndex = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+  <head>
+    <meta charset="utf-8" />
+    <title>Email Previews</title>
+  </head>
+  <body>
+    <h1>Email Previews</h1>
+    {{ range $name := .Templates }}
+    <h2>{{ $name }}</h2>
+    <ul>
+      {{ range $.Fixtures }}
+      <li><a href="/{{ $name }}/{{ .Name }}">{{ .Name }}</a></li>
+      {{ end }}
+    </ul>
+    {{ end }}
+  </body>
+</html>
+`))
+
+// PreviewHandler serves every registered email template rendered with each of the PreviewFixtures.
+// GET / lists the previews and GET /{template}/{fixture} renders one.
+func PreviewHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.Trim(r.URL.Path, "/")
+		if path == "" {
+			err := previewIndex.Execute(w, struct {
+				Templates []string
+				Fixtures  []PreviewFixture
+			}{EmailTemplateNames(), PreviewFixtures()})
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		parts := strings.Split(path, "/")
+		if len(parts) != 2 {
+			http.NotFound(w, r)
+			return
+		}
+		fixture, ok := previewFixture(parts[1])
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown fixture: '%s'", parts[1]), http.StatusNotFound)
+			return
+		}
+		if _, ok := emailTemplates[parts[0]]; !ok {
+			http.Error(w, fmt.Sprintf("unknown email template: '%s'", parts[0]), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err := RenderEmail(w, parts[0], previewSignup(parts[0], fixture))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// previewSignup returns the fixture's Signup for the program that sends the named email template.
+func previewSignup(template string, f PreviewFixture) Signup {
+	s := f.Signup
+	s.ProgramId = programForTemplate(template)
+	return s
+}
+
+func previewFixture(name string) (PreviewFixture, bool) {
+	for _, f := range PreviewFixtures() {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return PreviewFixture{}, false
+}
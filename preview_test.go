@@ -0,0 +1,71 @@
+package signups
+
+import (
+	"bytes"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata/")
+
+// TestEmailGolden renders every registered email template with every preview fixture and compares
+// the result to the snapshot in testdata/. Run `go test -run TestEmailGolden -update` to accept changes.
+func TestEmailGolden(t *testing.T) {
+	for _, name := range EmailTemplateNames() {
+		for _, fixture := range PreviewFixtures() {
+			t.Run(name+"/"+fixture.Name, func(t *testing.T) {
+				var got bytes.Buffer
+				err := RenderEmail(&got, name, previewSignup(name, fixture))
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+
+				golden := filepath.Join("testdata", name, fixture.Name+".golden.html")
+				if *update {
+					if err := os.MkdirAll(filepath.Dir(golden), 0755); err != nil {
+						t.Fatal(err)
+					}
+					if err := os.WriteFile(golden, got.Bytes(), 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				want, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatalf("reading golden file (run with -update to create it): %s", err)
+				}
+				if !bytes.Equal(want, got.Bytes()) {
+					t.Errorf("rendered HTML does not match %s\nrun `go test -run TestEmailGolden -update` if the change is intended", golden)
+				}
+			})
+		}
+	}
+}
+
+func TestPreviewHandler(t *testing.T) {
+	tests := []struct {
+		path       string
+		wantStatus int
+		want       string
+	}{
+		{"/", http.StatusOK, `href="/info-session-welcome/with-session"`},
+		{"/info-session-welcome/accented-names", http.StatusOK, "Hi María José,"},
+		{"/info-session-welcome/nope", http.StatusNotFound, "unknown fixture"},
+		{"/nope/with-session", http.StatusNotFound, "unknown email template"},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		PreviewHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if rec.Code != test.wantStatus {
+			t.Errorf("GET %s: want status %d, got %d", test.path, test.wantStatus, rec.Code)
+		}
+		if !bytes.Contains(rec.Body.Bytes(), []byte(test.want)) {
+			t.Errorf("GET %s: body missing %q", test.path, test.want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package signups
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Program is an Operation Spark program intake handled by this service, such as Info Sessions or Workshops.
+// A Signup's ProgramId selects the welcome email, Slack channel, and Greenlight endpoint it is sent to.
+type Program struct {
+	Id   string
+	Name string
+	// EmailTemplate is the name of the registered email template sent to new signups.
+	EmailTemplate string
+	EmailSubject  string
+	EmailSender   string
+	// SlackWebhookURL is the Incoming Webhook that posts the program's signups to Slack.
+	SlackWebhookURL string
+	// SlackChannel is the ID of the same channel, used instead of the webhook when SLACK_BOT_TOKEN is set.
+	SlackChannel string
+	// GreenlightURL is the Greenlight endpoint that records the program's signups.
+	GreenlightURL string
+}
+
+// DefaultProgramId is the program used for signups without a ProgramId, or with one this service doesn't handle.
+const DefaultProgramId = "info-session"
+
+// Programs returns every program this service handles, keyed by ProgramId.
+// Each program's Slack and Greenlight endpoints can be set with environment variables and fall back to the Info Session endpoints.
+func Programs() map[string]Program {
+	sender := fmt.Sprintf("Operation Spark <admissions@%s>", os.Getenv("MAIL_DOMAIN"))
+	greenlightURL := os.Getenv("GREENLIGHT_WEBHOOK_URL")
+
+	return map[string]Program{
+		DefaultProgramId: {
+			Id:              DefaultProgramId,
+			Name:            "Info Session",
+			EmailTemplate:   "info-session-welcome",
+			EmailSubject:    "Welcome from Operation Spark!",
+			EmailSender:     sender,
+			SlackWebhookURL: SLACK_WEBHOOK_URL,
+			SlackChannel:    os.Getenv("SLACK_CHANNEL_ID"),
+			GreenlightURL:   greenlightURL,
+		},
+		"workshop": {
+			Id:              "workshop",
+			Name:            "Coding Workshop",
+			EmailTemplate:   "workshop-welcome",
+			EmailSubject:    "Welcome to your Operation Spark Workshop!",
+			EmailSender:     sender,
+			SlackWebhookURL: envOr("WORKSHOP_SLACK_WEBHOOK_URL", SLACK_WEBHOOK_URL),
+			SlackChannel:    envOr("WORKSHOP_SLACK_CHANNEL_ID", os.Getenv("SLACK_CHANNEL_ID")),
+			GreenlightURL:   envOr("WORKSHOP_GREENLIGHT_WEBHOOK_URL", greenlightURL),
+		},
+	}
+}
+
+// Program looks up the Signup's program by ProgramId, falling back to the default (Info Session) program.
+func (s *Signup) Program() Program {
+	programs := Programs()
+	if p, ok := programs[s.ProgramId]; ok {
+		return p
+	}
+	return programs[DefaultProgramId]
+}
+
+// programForTemplate returns the ID of the first program (alphabetically) that sends the named email template.
+func programForTemplate(name string) string {
+	programs := Programs()
+	ids := make([]string, 0, len(programs))
+	for id := range programs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if programs[id].EmailTemplate == name {
+			return id
+		}
+	}
+	return DefaultProgramId
+}
+
+// envOr returns the value of the environment variable named by key, or fallback if it is empty.
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
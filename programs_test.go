@@ -0,0 +1,64 @@
+package signups
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgram(t *testing.T) {
+	tests := []struct {
+		programId string
+		want      string
+	}{
+		{"", DefaultProgramId},
+		{"info-session", DefaultProgramId},
+		{"workshop", "workshop"},
+		{"not-a-program", DefaultProgramId},
+	}
+
+	for _, test := range tests {
+		s := Signup{ProgramId: test.programId}
+		if got := s.Program().Id; got != test.want {
+			t.Errorf("Signup{ProgramId: %q}.Program(): want %q, got %q", test.programId, test.want, got)
+		}
+	}
+}
+
+func TestProgramEndpoints(t *testing.T) {
+	t.Setenv("GREENLIGHT_WEBHOOK_URL", "https://greenlight.example.com/api/signup")
+	t.Setenv("WORKSHOP_GREENLIGHT_WEBHOOK_URL", "")
+	t.Setenv("WORKSHOP_SLACK_WEBHOOK_URL", "https://hooks.slack.example.com/workshops")
+
+	workshop := Programs()["workshop"]
+	if workshop.GreenlightURL != "https://greenlight.example.com/api/signup" {
+		t.Errorf("workshop GreenlightURL should fall back to GREENLIGHT_WEBHOOK_URL, got %q", workshop.GreenlightURL)
+	}
+	if workshop.SlackWebhookURL != "https://hooks.slack.example.com/workshops" {
+		t.Errorf("workshop SlackWebhookURL should be WORKSHOP_SLACK_WEBHOOK_URL, got %q", workshop.SlackWebhookURL)
+	}
+}
+
+func TestProgramEmail(t *testing.T) {
+	sessionStart, _ := time.Parse(time.RFC822, "02 Feb 22 15:00 UTC")
+	s := Signup{ProgramId: "workshop", NameFirst: "Tariq", NameLast: "Trotter", StartDateTime: sessionStart, Cohort: "ws-feb-02-22-9am"}
+
+	var b bytes.Buffer
+	if err := s.html(&b); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, want := range []string{"<title>Workshop Confirmation</title>", "registering for the Coding Workshop", "Wednesday, Feb 02 at 9:00 AM CST"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("workshop email missing %q", want)
+		}
+	}
+	if strings.Contains(b.String(), "info session") {
+		t.Errorf("workshop email should not mention info sessions")
+	}
+
+	want := "Tariq Trotter has signed up for ws-feb-02-22-9am (Coding Workshop)."
+	if got := s.Summary(); !strings.Contains(got, want) {
+		t.Errorf("s.Summary(): want %q in\n%s", want, got)
+	}
+}
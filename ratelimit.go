@@ -0,0 +1,140 @@
+package signups
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"mime/multipart"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RATE_LIMIT_IP and RATE_LIMIT_EMAIL limit signups per source IP and per email address, as "count/duration".
+// "20/1h" allows bursts of up to 20 signups, refilled at 20 an hour. "off" turns the limit off.
+var RATE_LIMIT_IP = envOr("RATE_LIMIT_IP", "20/1h")
+var RATE_LIMIT_EMAIL = envOr("RATE_LIMIT_EMAIL", "5/1h")
+
+// HONEYPOT_FIELD is a form field hidden from people. Bots fill in every field, so signups with a value in it are dropped.
+var HONEYPOT_FIELD = envOr("HONEYPOT_FIELD", "website")
+
+// Limit is a token bucket: it holds up to Burst tokens and refills at Rate tokens per second. Each request takes a token.
+type Limit struct {
+	Rate  float64
+	Burst float64
+}
+
+// RateLimiter keeps token buckets. The signup stores are RateLimiters, but their buckets are only shared by instances
+// using the same database file. Set REDIS_URL to limit signups across every instance.
+type RateLimiter interface {
+	// Take takes a token from the key's bucket at now. If the bucket is empty, it returns false and how long until it has a token.
+	Take(ctx context.Context, key string, l Limit, now time.Time) (bool, time.Duration, error)
+}
+
+// Limiter keeps the rate limit buckets when it's set: a RedisLimiter if REDIS_URL is set. Otherwise the signup store keeps them,
+// so on Cloud Functions (where each instance has its own SQLite file) the limits are per instance.
+var Limiter RateLimiter = openLimiter()
+
+// parseLimit parses a "count/duration" limit. It returns false if the limit is "off".
+func parseLimit(s string) (Limit, bool, error) {
+	if s == "off" {
+		return Limit{}, false, nil
+	}
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return Limit{}, false, fmt.Errorf("invalid rate limit %q, want count/duration (e.g. 20/1h)", s)
+	}
+	count, err := strconv.Atoi(parts[0])
+	if err != nil || count < 1 {
+		return Limit{}, false, fmt.Errorf("invalid rate limit count %q", parts[0])
+	}
+	per, err := time.ParseDuration(parts[1])
+	if err != nil || per <= 0 {
+		return Limit{}, false, fmt.Errorf("invalid rate limit duration %q", parts[1])
+	}
+	return Limit{Rate: float64(count) / per.Seconds(), Burst: float64(count)}, true, nil
+}
+
+// refill returns how many tokens a bucket that had tokens at updated has at now.
+func (l Limit) refill(tokens float64, updated, now time.Time) float64 {
+	elapsed := now.Sub(updated)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return math.Min(l.Burst, tokens+elapsed.Seconds()*l.Rate)
+}
+
+// wait returns how long until a bucket with tokens has a whole token.
+func (l Limit) wait(tokens float64) time.Duration {
+	return time.Duration((1 - tokens) / l.Rate * float64(time.Second))
+}
+
+// rateLimited takes a token for the key from the limit ("count/duration") and returns how long until the next request is allowed, or 0 if this one is.
+// Requests are allowed when the limit is off or can't be checked, so a broken limit never blocks signups.
+func rateLimited(ctx context.Context, limit, key string) time.Duration {
+	limiter := Limiter
+	if limiter == nil {
+		store, ok := signupStore.(RateLimiter)
+		if !ok {
+			return 0
+		}
+		limiter = store
+	}
+	l, on, err := parseLimit(limit)
+	if err != nil {
+		fmt.Printf("ignoring %s\n", err.Error())
+		return 0
+	}
+	if !on {
+		return 0
+	}
+	allowed, retryAfter, err := limiter.Take(ctx, key, l, time.Now())
+	if err != nil {
+		fmt.Printf("error checking rate limit %s %s\n", key, err.Error())
+		return 0
+	}
+	if allowed {
+		return 0
+	}
+	return retryAfter
+}
+
+// retryAfterSeconds formats the Retry-After header value, rounding up to a whole second.
+func retryAfterSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Max(1, math.Ceil(d.Seconds()))))
+}
+
+// honeypotFilled reports whether the signup request body has a value in the HONEYPOT_FIELD.
+func honeypotFilled(contentType string, body []byte) bool {
+	if HONEYPOT_FIELD == "" {
+		return false
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch mediaType {
+	case "multipart/form-data":
+		form, err := multipart.NewReader(bytes.NewReader(body), params["boundary"]).ReadForm(maxSignupBytes)
+		if err != nil {
+			return false
+		}
+		defer form.RemoveAll()
+		return len(form.Value[HONEYPOT_FIELD]) > 0 && strings.TrimSpace(form.Value[HONEYPOT_FIELD][0]) != ""
+	case "application/x-www-form-urlencoded":
+		form, err := url.ParseQuery(string(body))
+		return err == nil && strings.TrimSpace(form.Get(HONEYPOT_FIELD)) != ""
+	case "application/json":
+		var fields map[string]interface{}
+		if json.Unmarshal(body, &fields) != nil {
+			return false
+		}
+		v, ok := fields[HONEYPOT_FIELD]
+		return ok && v != nil && v != ""
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package signups
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// REDIS_URL is the Redis server the rate limit buckets are kept in, e.g. a Memorystore instance's redis://10.0.0.3:6379.
+// Every instance shares it, so the limits apply across instances. Without it, the signup store keeps the buckets.
+var REDIS_URL = os.Getenv("REDIS_URL")
+
+// openLimiter returns a RedisLimiter for REDIS_URL, or nil to keep the buckets in the signup store.
+func openLimiter() RateLimiter {
+	if REDIS_URL == "" {
+		return nil
+	}
+	return NewRedisLimiter(REDIS_URL)
+}
+
+// takeScript takes a token from the bucket in KEYS[1], a hash of its tokens and when they were updated (in microseconds).
+// ARGV is the burst, the rate in tokens per microsecond, and now. It returns whether a token was taken and the tokens left.
+// Redis runs scripts one at a time, so instances can't both take the last token.
+var takeScript = redis.NewScript(1, `
+local burst = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "updated")
+local tokens = burst
+if bucket[1] then
+	tokens = math.min(burst, tonumber(bucket[1]) + math.max(0, now - tonumber(bucket[2])) * rate)
+end
+if tokens < 1 then
+	return {0, tostring(tokens)}
+end
+tokens = tokens - 1
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "updated", ARGV[3])
+redis.call("PEXPIRE", KEYS[1], math.ceil((burst - tokens) / rate / 1000) + 1)
+return {1, tostring(tokens)}
+`)
+
+// RedisLimiter keeps token buckets in Redis. Buckets expire once they'd be full again, since a full bucket is the same as no bucket.
+type RedisLimiter struct {
+	pool *redis.Pool
+}
+
+// NewRedisLimiter returns a RedisLimiter for the Redis server at the URL (redis:// or rediss://). Connections are made when they're needed.
+func NewRedisLimiter(url string) *RedisLimiter {
+	return &RedisLimiter{pool: &redis.Pool{
+		MaxIdle:     3,
+		IdleTimeout: 4 * time.Minute,
+		Dial: func() (redis.Conn, error) {
+			return redis.DialURL(url,
+				redis.DialConnectTimeout(2*time.Second),
+				redis.DialReadTimeout(2*time.Second),
+				redis.DialWriteTimeout(2*time.Second),
+			)
+		},
+	}}
+}
+
+func (r *RedisLimiter) Take(ctx context.Context, key string, l Limit, now time.Time) (bool, time.Duration, error) {
+	conn, err := r.pool.GetContext(ctx)
+	if err != nil {
+		return false, 0, fmt.Errorf("connecting to Redis: %w", err)
+	}
+	defer conn.Close()
+
+	reply, err := redis.Values(takeScript.Do(conn, "signups:ratelimit:"+key, l.Burst, l.Rate/1e6, now.UnixNano()/1e3))
+	if err != nil {
+		return false, 0, err
+	}
+	if len(reply) != 2 {
+		return false, 0, fmt.Errorf("unexpected rate limit script reply %v", reply)
+	}
+	allowed, err := redis.Bool(reply[0], nil)
+	if err != nil {
+		return false, 0, err
+	}
+	tokens, err := redis.Float64(reply[1], nil)
+	if err != nil {
+		return false, 0, err
+	}
+	if allowed {
+		return true, 0, nil
+	}
+	return false, l.wait(tokens), nil
+}
+
+// Close closes the limiter's idle connections.
+func (r *RedisLimiter) Close() error {
+	return r.pool.Close()
+}
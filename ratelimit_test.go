@@ -0,0 +1,246 @@
+package signups
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		limit   string
+		want    Limit
+		wantOn  bool
+		wantErr bool
+	}{
+		{"20/1h", Limit{Rate: 20.0 / 3600, Burst: 20}, true, false},
+		{"1/2s", Limit{Rate: 0.5, Burst: 1}, true, false},
+		{"off", Limit{}, false, false},
+		{"20", Limit{}, false, true},
+		{"0/1h", Limit{}, false, true},
+		{"20/hour", Limit{}, false, true},
+	}
+	for _, test := range tests {
+		got, on, err := parseLimit(test.limit)
+		if (err != nil) != test.wantErr || on != test.wantOn {
+			t.Errorf("parseLimit(%q) = %v, %v; wantOn %v, wantErr %v", test.limit, on, err, test.wantOn, test.wantErr)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("parseLimit(%q) mismatch (-want +got):\n%s", test.limit, diff)
+		}
+	}
+}
+
+func TestRateLimiters(t *testing.T) {
+	limiters := map[string]func(t *testing.T) RateLimiter{
+		"memory": func(t *testing.T) RateLimiter { return NewMemoryStore() },
+		"sqlite": func(t *testing.T) RateLimiter {
+			s, err := OpenSQLiteStore(filepath.Join(t.TempDir(), "signups.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { s.Close() })
+			return s
+		},
+		"redis": func(t *testing.T) RateLimiter {
+			r := NewRedisLimiter("redis://" + miniredis.RunT(t).Addr())
+			t.Cleanup(func() { r.Close() })
+			return r
+		},
+	}
+
+	// Bursts of 2, refilled at 1 a minute
+	l := Limit{Rate: 1.0 / 60, Burst: 2}
+	start := time.Unix(1650000000, 0)
+	steps := []struct {
+		key       string
+		at        time.Duration
+		want      bool
+		wantRetry time.Duration
+	}{
+		{"ip:203.0.113.7", 0, true, 0},
+		{"ip:203.0.113.7", time.Second, true, 0},
+		{"ip:203.0.113.7", 2 * time.Second, false, 58 * time.Second},
+		{"ip:198.51.100.1", 2 * time.Second, true, 0},
+		{"ip:203.0.113.7", 30 * time.Second, false, 30 * time.Second},
+		{"ip:203.0.113.7", 61 * time.Second, true, 0},
+		{"ip:203.0.113.7", 61 * time.Second, false, 59 * time.Second},
+	}
+
+	for name, open := range limiters {
+		t.Run(name, func(t *testing.T) {
+			limiter := open(t)
+			for i, step := range steps {
+				ok, retry, err := limiter.Take(context.Background(), step.key, l, start.Add(step.at))
+				if err != nil {
+					t.Fatal(err)
+				}
+				// Refill math is in floating point
+				diff := retry - step.wantRetry
+				if ok != step.want || diff > time.Millisecond || diff < -time.Millisecond {
+					t.Errorf("step %d: Take(%s) at %s = %v, %s; want %v, %s", i, step.key, step.at, ok, retry, step.want, step.wantRetry)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleSignUpRateLimits(t *testing.T) {
+	t.Setenv("DISABLE_GREENLIGHT", "true")
+	t.Setenv("DISABLE_SLACK", "true")
+	defer setVar(&RATE_LIMIT_IP, "3/1h")()
+	defer setVar(&RATE_LIMIT_EMAIL, "2/1h")()
+	defer setVar(&HONEYPOT_FIELD, "website")()
+	defer setStore(NewMemoryStore())()
+	defer setTrustedProxies(true, "")()
+
+	tests := []struct {
+		name           string
+		ip             string
+		body           string
+		wantStatus     int
+		wantRetryAfter string
+	}{
+		{"first", "203.0.113.7", "nameFirst=Henri&nameLast=Testaroni&email=henri@email.com&website=", http.StatusOK, ""},
+		{"second", "203.0.113.7", "nameFirst=Henri&nameLast=Testaroni&email=henri@email.com", http.StatusOK, ""},
+		{"email limit", "198.51.100.1", "nameFirst=Henri&nameLast=Testaroni&email=Henri%2Bagain@email.com", http.StatusTooManyRequests, "1800"},
+		{"another email", "203.0.113.7", "nameFirst=Solána&nameLast=Rowe&email=solana@email.com", http.StatusOK, ""},
+		// A client can't get around the limit by sending its own X-Forwarded-For
+		{"IP limit", "198.51.100.99, 203.0.113.7", "nameFirst=Quinta&nameLast=Brunson&email=quinta@email.com", http.StatusTooManyRequests, "1200"},
+		{"honeypot", "192.0.2.10", "nameFirst=Bot&nameLast=Bot&email=bot@email.com&website=http%3A%2F%2Fspam.example.com", http.StatusOK, ""},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(test.body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-Forwarded-For", test.ip)
+		w := httptest.NewRecorder()
+		HandleSignUp(w, req)
+		if w.Code != test.wantStatus || w.Header().Get("Retry-After") != test.wantRetryAfter {
+			t.Errorf("%s: want %d with Retry-After %q, got %d %q %s", test.name, test.wantStatus, test.wantRetryAfter, w.Code, w.Header().Get("Retry-After"), w.Body)
+		}
+		var resp SignupResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Errorf("%s: want a JSON response, got %q", test.name, w.Body)
+		}
+	}
+
+	// The honeypot signup looked successful but wasn't stored
+	records, _ := signupStore.Find(context.Background(), Query{})
+	emails := []string{}
+	for _, r := range records {
+		emails = append(emails, r.Signup.Email)
+	}
+	if diff := cmp.Diff([]string{"henri@email.com", "henri@email.com", "solana@email.com"}, emails); diff != "" {
+		t.Errorf("stored signups mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSourceIP(t *testing.T) {
+	defer setTrustedProxies(false, "35.191.0.0/16, 130.211.0.0/22, 10.0.0.0/8")()
+	tests := []struct {
+		name         string
+		frontEnd     bool
+		remoteAddr   string
+		forwardedFor string
+		want         string
+	}{
+		{"direct", false, "203.0.113.7:1234", "", "203.0.113.7"},
+		{"direct with a spoofed header", false, "203.0.113.7:1234", "198.51.100.99", "203.0.113.7"},
+		{"front end", true, "169.254.8.1:1234", "203.0.113.7", "203.0.113.7"},
+		{"front end without the header", true, "169.254.8.1:1234", "", "169.254.8.1"},
+		{"spoofed first hop", true, "169.254.8.1:1234", "198.51.100.99, 203.0.113.7", "203.0.113.7"},
+		{"load balancer", true, "169.254.8.1:1234", "198.51.100.99,203.0.113.7, 35.191.0.1", "203.0.113.7"},
+		{"trusted proxy", false, "10.1.2.3:1234", "198.51.100.99, 203.0.113.7", "203.0.113.7"},
+		{"only proxies", true, "169.254.8.1:1234", "35.191.0.2, 130.211.0.1", "35.191.0.2"},
+		{"garbage hop", true, "169.254.8.1:1234", "203.0.113.7, unknown", "169.254.8.1"},
+		{"garbage before the client", true, "169.254.8.1:1234", "unknown, 203.0.113.7", "203.0.113.7"},
+	}
+	for _, test := range tests {
+		behindGoogleFrontEnd = test.frontEnd
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.RemoteAddr = test.remoteAddr
+		if test.forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", test.forwardedFor)
+		}
+		if got := sourceIP(req); got != test.want {
+			t.Errorf("%s: sourceIP() = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestParseCIDRs(t *testing.T) {
+	nets := parseCIDRs("TRUSTED_PROXIES", "35.191.0.0/16, oops,,2600:1900::/28")
+	got := []string{}
+	for _, n := range nets {
+		got = append(got, n.String())
+	}
+	if diff := cmp.Diff([]string{"35.191.0.0/16", "2600:1900::/28"}, got); diff != "" {
+		t.Errorf("parseCIDRs() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// setTrustedProxies sets whether requests come through Google's front end and the trusted proxy CIDRs for the duration of a test.
+// Defer the returned func to restore them.
+func setTrustedProxies(frontEnd bool, cidrs string) func() {
+	prevFrontEnd, prevProxies := behindGoogleFrontEnd, trustedProxies
+	behindGoogleFrontEnd, trustedProxies = frontEnd, parseCIDRs("TRUSTED_PROXIES", cidrs)
+	return func() { behindGoogleFrontEnd, trustedProxies = prevFrontEnd, prevProxies }
+}
+
+// denyAll is a RateLimiter whose buckets are always empty.
+type denyAll struct{}
+
+func (denyAll) Take(ctx context.Context, key string, l Limit, now time.Time) (bool, time.Duration, error) {
+	return false, time.Minute, nil
+}
+
+func TestLimiter(t *testing.T) {
+	defer setStore(NewMemoryStore())()
+	defer func(prev RateLimiter) { Limiter = prev }(Limiter)
+
+	Limiter = nil
+	if wait := rateLimited(context.Background(), "20/1h", "ip:203.0.113.7"); wait != 0 {
+		t.Errorf("want the store's buckets used by default, got wait %s", wait)
+	}
+	Limiter = denyAll{}
+	if wait := rateLimited(context.Background(), "20/1h", "ip:203.0.113.7"); wait != time.Minute {
+		t.Errorf("want Limiter's buckets used when it's set, got wait %s", wait)
+	}
+	Limiter = NewRedisLimiter("redis://127.0.0.1:1")
+	if wait := rateLimited(context.Background(), "20/1h", "ip:203.0.113.7"); wait != 0 {
+		t.Errorf("want requests allowed when Redis is down, got wait %s", wait)
+	}
+}
+
+func TestRedisLimiterShared(t *testing.T) {
+	server := miniredis.RunT(t)
+	// Two instances using the same Redis server
+	a, b := NewRedisLimiter("redis://"+server.Addr()), NewRedisLimiter("redis://"+server.Addr())
+	defer a.Close()
+	defer b.Close()
+
+	l := Limit{Rate: 1.0 / 60, Burst: 2}
+	now := time.Now()
+	for i, limiter := range []*RedisLimiter{a, b, a, b} {
+		ok, _, err := limiter.Take(context.Background(), "ip:203.0.113.7", l, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := i < 2; ok != want {
+			t.Errorf("take %d: want %v, got %v", i, want, ok)
+		}
+	}
+
+	// Buckets expire once they'd be full again
+	if ttl := server.TTL("signups:ratelimit:ip:203.0.113.7"); ttl <= time.Minute || ttl > 2*time.Minute+time.Second {
+		t.Errorf("want the bucket to expire in about 2m, got %s", ttl)
+	}
+}
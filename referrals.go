@@ -0,0 +1,328 @@
+package signups
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Referral channels are the canonical answers to "How did you hear about us?".
+const (
+	ChannelInstagram   = "instagram"
+	ChannelFacebook    = "facebook"
+	ChannelTikTok      = "tiktok"
+	ChannelLinkedIn    = "linkedin"
+	ChannelTwitter     = "twitter"
+	ChannelYouTube     = "youtube"
+	ChannelSearch      = "search"
+	ChannelWebsite     = "website"
+	ChannelEmail       = "email"
+	ChannelWordOfMouth = "word of mouth"
+	ChannelEvent       = "event"
+	ChannelPartner     = "community partner"
+	ChannelSchool      = "school"
+	ChannelJobCenter   = "job center"
+	ChannelMedia       = "news & media"
+	// ChannelOther is an answer that doesn't match any alias. ChannelNotGiven is no answer at all.
+	ChannelOther    = "other"
+	ChannelNotGiven = "not given"
+)
+
+// referralAliases map normalized referrer answers and UTM sources to referral channels.
+// Answers that contain an alias as a phrase match it too, e.g. "Instagram ad" or "word of mouth from Henri".
+var referralAliases = map[string]string{
+	"instagram": ChannelInstagram, "ig": ChannelInstagram, "insta": ChannelInstagram,
+	"facebook": ChannelFacebook, "fb": ChannelFacebook, "meta": ChannelFacebook,
+	"tiktok": ChannelTikTok, "tik tok": ChannelTikTok,
+	"linkedin": ChannelLinkedIn, "linked in": ChannelLinkedIn,
+	"twitter": ChannelTwitter, "tweet": ChannelTwitter,
+	"youtube": ChannelYouTube, "yt": ChannelYouTube,
+	"google": ChannelSearch, "bing": ChannelSearch, "search": ChannelSearch, "internet": ChannelSearch,
+	"website": ChannelWebsite, "operationspark org": ChannelWebsite, "operation spark website": ChannelWebsite,
+	"email": ChannelEmail, "e mail": ChannelEmail, "newsletter": ChannelEmail, "mailchimp": ChannelEmail,
+	"word of mouth": ChannelWordOfMouth, "wom": ChannelWordOfMouth, "friend": ChannelWordOfMouth, "family": ChannelWordOfMouth,
+	"referral": ChannelWordOfMouth, "referred": ChannelWordOfMouth, "coworker": ChannelWordOfMouth, "alum": ChannelWordOfMouth, "alumni": ChannelWordOfMouth,
+	"event": ChannelEvent, "career fair": ChannelEvent, "job fair": ChannelEvent, "meetup": ChannelEvent,
+	"partner": ChannelPartner, "community partner": ChannelPartner, "nonprofit": ChannelPartner, "church": ChannelPartner, "library": ChannelPartner,
+	"school": ChannelSchool, "college": ChannelSchool, "university": ChannelSchool, "teacher": ChannelSchool, "counselor": ChannelSchool,
+	"job center": ChannelJobCenter, "career center": ChannelJobCenter, "workforce": ChannelJobCenter, "unemployment": ChannelJobCenter,
+	"news": ChannelMedia, "newspaper": ChannelMedia, "radio": ChannelMedia, "tv": ChannelMedia, "television": ChannelMedia, "podcast": ChannelMedia,
+}
+
+// REFERRAL_ALIASES adds to (or overrides) the built-in referral aliases without a deploy: a JSON object of alias to channel, e.g. {"nola tech week": "event"}.
+var REFERRAL_ALIASES = os.Getenv("REFERRAL_ALIASES")
+
+// aliases returns the built-in referral aliases with the REFERRAL_ALIASES additions.
+func aliases() map[string]string {
+	if REFERRAL_ALIASES == "" {
+		return referralAliases
+	}
+	var extra map[string]string
+	err := json.Unmarshal([]byte(REFERRAL_ALIASES), &extra)
+	if err != nil {
+		fmt.Printf("ignoring REFERRAL_ALIASES: %s\n", err)
+		return referralAliases
+	}
+	merged := make(map[string]string, len(referralAliases)+len(extra))
+	for alias, channel := range referralAliases {
+		merged[alias] = channel
+	}
+	for alias, channel := range extra {
+		merged[normalizeReferral(alias)] = channel
+	}
+	return merged
+}
+
+// normalizeReferral lowercases the answer and replaces punctuation with spaces, e.g. "Instagram-Ad!" becomes "instagram ad".
+func normalizeReferral(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	}), " ")
+}
+
+// ReferralChannel maps a free-text answer or UTM source to its referral channel, using the alias that matches the longest phrase.
+// Of matching aliases with the same length, the one mentioned first in the answer wins, e.g. "ig and fb" is Instagram.
+func ReferralChannel(answer string) string {
+	text := normalizeReferral(answer)
+	if text == "" {
+		return ChannelNotGiven
+	}
+	all := aliases()
+	if channel, ok := all[text]; ok {
+		return channel
+	}
+	padded := " " + text + " "
+	channel, longest, first := ChannelOther, 0, 0
+	for alias, c := range all {
+		at := strings.Index(padded, " "+alias+" ")
+		if at < 0 {
+			continue
+		}
+		if len(alias) > longest || len(alias) == longest && at < first {
+			channel, longest, first = c, len(alias), at
+		}
+	}
+	return channel
+}
+
+// Attribution is where a signup came from.
+type Attribution struct {
+	Channel     string `json:"channel"`
+	Source      string `json:"utmSource"`
+	Medium      string `json:"utmMedium"`
+	Campaign    string `json:"utmCampaign"`
+	LandingPage string `json:"landingPage"`
+}
+
+// Attribution returns the Signup's referral channel and UTM parameters.
+// UTM parameters missing from the form are read from the landing page URL. The channel is taken from the UTM source, then the referrer answers.
+func (s *Signup) Attribution() Attribution {
+	a := Attribution{Source: s.UtmSource, Medium: s.UtmMedium, Campaign: s.UtmCampaign, LandingPage: s.LandingPage}
+	if u, err := url.Parse(s.LandingPage); err == nil {
+		q := u.Query()
+		if a.Source == "" {
+			a.Source = q.Get("utm_source")
+		}
+		if a.Medium == "" {
+			a.Medium = q.Get("utm_medium")
+		}
+		if a.Campaign == "" {
+			a.Campaign = q.Get("utm_campaign")
+		}
+	}
+
+	a.Channel = ChannelNotGiven
+	for _, answer := range []string{a.Source, s.Referrer, s.ReferrerResponse} {
+		channel := ReferralChannel(answer)
+		if channel == ChannelNotGiven {
+			continue
+		}
+		// A recognized answer beats an unrecognized one
+		if a.Channel == ChannelNotGiven || a.Channel == ChannelOther {
+			a.Channel = channel
+		}
+		if channel != ChannelOther {
+			break
+		}
+	}
+	return a
+}
+
+// ReferralCount is the number of signups from a referral channel during a period.
+type ReferralCount struct {
+	// Period is the first day of the period, e.g. "2022-03-14".
+	Period  string `json:"period"`
+	Channel string `json:"channel"`
+	Signups int    `json:"signups"`
+}
+
+// ReferralReport counts signups by referral channel over time.
+type ReferralReport struct {
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+	// Interval is the length of each period: "day", "week" (starting Monday), or "month".
+	Interval string          `json:"interval"`
+	Counts   []ReferralCount `json:"counts"`
+	// Totals are the signups from each channel during the whole report.
+	Totals map[string]int `json:"totals"`
+}
+
+// periodStart returns the start of the interval containing t, in Central time.
+func periodStart(t time.Time, interval string) (time.Time, error) {
+	y, m, d := t.In(centralTZ()).Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, centralTZ())
+	switch interval {
+	case "day":
+		return day, nil
+	case "week":
+		// Weeks start on Monday
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7), nil
+	case "month":
+		return time.Date(y, m, 1, 0, 0, 0, 0, centralTZ()), nil
+	}
+	return time.Time{}, fmt.Errorf("unknown report interval: '%s'. Use 'day', 'week', or 'month'", interval)
+}
+
+// BuildReferralReport counts the signups received in [since, until) by referral channel and interval.
+func BuildReferralReport(ctx context.Context, since, until time.Time, interval string) (ReferralReport, error) {
+	if _, err := periodStart(since, interval); err != nil {
+		return ReferralReport{}, err
+	}
+	records, err := signupStore.Find(ctx, Query{Since: since, Until: until})
+	if err != nil {
+		return ReferralReport{}, err
+	}
+
+	type key struct{ period, channel string }
+	counts := map[key]int{}
+	report := ReferralReport{Since: since, Until: until, Interval: interval, Counts: []ReferralCount{}, Totals: map[string]int{}}
+	for _, rec := range records {
+		start, _ := periodStart(rec.ReceivedAt, interval)
+		channel := rec.Signup.Attribution().Channel
+		counts[key{start.Format("2006-01-02"), channel}]++
+		report.Totals[channel]++
+	}
+	for k, n := range counts {
+		report.Counts = append(report.Counts, ReferralCount{Period: k.period, Channel: k.channel, Signups: n})
+	}
+	sort.Slice(report.Counts, func(i, j int) bool {
+		a, b := report.Counts[i], report.Counts[j]
+		if a.Period != b.Period {
+			return a.Period < b.Period
+		}
+		if a.Signups != b.Signups {
+			return a.Signups > b.Signups
+		}
+		return a.Channel < b.Channel
+	})
+	return report, nil
+}
+
+// WriteCSV writes the report's counts as CSV with a header row.
+func (r ReferralReport) WriteCSV(w *csv.Writer) error {
+	err := w.Write([]string{"period", "channel", "signups"})
+	if err != nil {
+		return err
+	}
+	for _, c := range r.Counts {
+		err := w.Write([]string{c.Period, c.Channel, strconv.Itoa(c.Signups)})
+		if err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// ParseSince and ParseUntil parse the start and end of a [since, until) range of signups: a date (Central time) or an RFC 3339 time.
+// A date starts the range at its midnight, and ends it at the next midnight, so "-since 2022-03-14 -until 2022-03-14" is that day.
+func ParseSince(value string) (time.Time, error) {
+	t, _, err := parseDate("since", value)
+	return t, err
+}
+
+func ParseUntil(value string) (time.Time, error) {
+	t, dateOnly, err := parseDate("until", value)
+	if err != nil || !dateOnly {
+		return t, err
+	}
+	return t.AddDate(0, 0, 1), nil
+}
+
+// parseDate parses a date (midnight Central time) or an RFC 3339 time, and reports whether it was a date.
+func parseDate(name, value string) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, centralTZ()); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid %s: '%s'. Use YYYY-MM-DD or RFC 3339", name, value)
+	}
+	return t, false, nil
+}
+
+// HandleReferralReport reports signups by referral channel over time. It requires the ADMIN_TOKEN.
+// Query parameters are "since" and "until" (YYYY-MM-DD, including the until date; default the last 90 days), "interval" ("day", "week" (default), or "month"),
+// and "format" ("json" (default) or "csv").
+func HandleReferralReport(w http.ResponseWriter, r *http.Request) {
+	if !authorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	q := r.URL.Query()
+	until := time.Now()
+	since := until.AddDate(0, 0, -90)
+	var err error
+	if v := q.Get("until"); v != "" {
+		if until, err = ParseUntil(v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("since"); v != "" {
+		if since, err = ParseSince(v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	interval := q.Get("interval")
+	if interval == "" {
+		interval = "week"
+	}
+	if _, err := periodStart(since, interval); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format := q.Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		http.Error(w, fmt.Sprintf("unknown format: '%s'. Use 'json' or 'csv'", format), http.StatusBadRequest)
+		return
+	}
+
+	report, err := BuildReferralReport(r.Context(), since, until, interval)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"referrals-%s-%s.csv\"", since.Format("2006-01-02"), until.Add(-time.Nanosecond).Format("2006-01-02")))
+		err = report.WriteCSV(csv.NewWriter(w))
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(report)
+	}
+	if err != nil {
+		fmt.Printf("error writing referral report %s\n", err.Error())
+	}
+}
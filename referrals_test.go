@@ -0,0 +1,175 @@
+package signups
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReferralChannel(t *testing.T) {
+	tests := []struct {
+		answer string
+		want   string
+	}{
+		{"instagram", ChannelInstagram},
+		{"IG", ChannelInstagram},
+		{"Instagram ad", ChannelInstagram},
+		{"  Facebook!! ", ChannelFacebook},
+		{"Word of mouth", ChannelWordOfMouth},
+		{"Word of mouth - Henri Testaroni", ChannelWordOfMouth},
+		{"my friend told me", ChannelWordOfMouth},
+		{"Career Center", ChannelJobCenter},
+		{"career fair", ChannelEvent},
+		{"google", ChannelSearch},
+		// Aliases with the same length: the first one mentioned wins
+		{"ig and fb", ChannelInstagram},
+		{"fb and ig", ChannelFacebook},
+		{"saw it on tv then yt", ChannelMedia},
+		{"a billboard", ChannelOther},
+		{"", ChannelNotGiven},
+	}
+	for _, test := range tests {
+		if got := ReferralChannel(test.answer); got != test.want {
+			t.Errorf("ReferralChannel(%q) = %q, want %q", test.answer, got, test.want)
+		}
+	}
+
+	defer setVar(&REFERRAL_ALIASES, `{"NOLA Tech Week": "event"}`)()
+	if got := ReferralChannel("nola tech week"); got != ChannelEvent {
+		t.Errorf("want REFERRAL_ALIASES alias to match %q, got %q", ChannelEvent, got)
+	}
+}
+
+func TestAttribution(t *testing.T) {
+	tests := []struct {
+		name   string
+		signup Signup
+		want   Attribution
+	}{
+		{
+			name:   "referrer answer",
+			signup: Signup{Referrer: "Word of mouth", ReferrerResponse: "Henri Testaroni"},
+			want:   Attribution{Channel: ChannelWordOfMouth},
+		},
+		{
+			name:   "UTM source beats the answer",
+			signup: Signup{Referrer: "Word of mouth", UtmSource: "ig", UtmMedium: "paid", UtmCampaign: "spring-22"},
+			want:   Attribution{Channel: ChannelInstagram, Source: "ig", Medium: "paid", Campaign: "spring-22"},
+		},
+		{
+			name:   "UTM parameters from the landing page",
+			signup: Signup{LandingPage: "https://operationspark.org/programs/workforce/infoSession?utm_source=facebook&utm_campaign=spring-22"},
+			want:   Attribution{Channel: ChannelFacebook, Source: "facebook", Campaign: "spring-22", LandingPage: "https://operationspark.org/programs/workforce/infoSession?utm_source=facebook&utm_campaign=spring-22"},
+		},
+		{
+			name:   "unrecognized referrer, recognized response",
+			signup: Signup{Referrer: "Other", ReferrerResponse: "saw it on tiktok"},
+			want:   Attribution{Channel: ChannelTikTok},
+		},
+		{
+			name:   "unrecognized",
+			signup: Signup{Referrer: "a billboard"},
+			want:   Attribution{Channel: ChannelOther},
+		},
+		{
+			name: "not given",
+			want: Attribution{Channel: ChannelNotGiven},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if diff := cmp.Diff(test.want, test.signup.Attribution()); diff != "" {
+				t.Errorf("Attribution() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHandleReferralReport(t *testing.T) {
+	defer setVar(&ADMIN_TOKEN, "admin-secret")()
+	defer setStore(NewMemoryStore())()
+	// Monday, Mar 7 and Monday, Mar 14, noon Central
+	week1, _ := time.Parse(time.RFC3339, "2022-03-07T18:00:00Z")
+	week2, _ := time.Parse(time.RFC3339, "2022-03-14T18:00:00Z")
+	seed := []struct {
+		referrer   string
+		receivedAt time.Time
+	}{
+		{"instagram", week1},
+		{"IG", week1.Add(48 * time.Hour)},
+		{"Word of mouth", week1.Add(72 * time.Hour)},
+		{"Instagram ad", week2},
+	}
+	for _, s := range seed {
+		rec := newRecord(Signup{Referrer: s.referrer})
+		rec.ReceivedAt = s.receivedAt
+		if err := signupStore.Save(context.Background(), rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	get := func(query, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/reports/referrals?"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		HandleReferralReport(w, req)
+		return w
+	}
+
+	if w := get("", "wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("want status %d without the admin token, got %d", http.StatusUnauthorized, w.Code)
+	}
+	if w := get("interval=fortnight", "admin-secret"); w.Code != http.StatusBadRequest {
+		t.Errorf("want status %d for an unknown interval, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	w := get("since=2022-03-01&until=2022-03-31&format=csv", "admin-secret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("want status %d, got %d: %s", http.StatusOK, w.Code, w.Body)
+	}
+	want := "period,channel,signups\n" +
+		"2022-03-07,instagram,2\n" +
+		"2022-03-07,word of mouth,1\n" +
+		"2022-03-14,instagram,1\n"
+	if diff := cmp.Diff(want, w.Body.String()); diff != "" {
+		t.Errorf("CSV mismatch (-want +got):\n%s", diff)
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.Contains(cd, "referrals-2022-03-01-2022-03-31.csv") {
+		t.Errorf("want CSV attachment, got Content-Disposition %q", cd)
+	}
+
+	w = get("since=2022-03-01&until=2022-03-31&interval=month", "admin-secret")
+	if !strings.Contains(w.Body.String(), `{"period":"2022-03-01","channel":"instagram","signups":3}`) {
+		t.Errorf("want monthly JSON counts, got %s", w.Body)
+	}
+}
+
+func TestParseSinceUntil(t *testing.T) {
+	ctz := centralTZ()
+	tests := []struct {
+		value     string
+		wantSince time.Time
+		wantUntil time.Time
+		wantErr   bool
+	}{
+		// A date-only range includes the whole day
+		{"2022-03-14", time.Date(2022, 3, 14, 0, 0, 0, 0, ctz), time.Date(2022, 3, 15, 0, 0, 0, 0, ctz), false},
+		{"2022-03-14T12:00:00-05:00", time.Date(2022, 3, 14, 12, 0, 0, 0, ctz), time.Date(2022, 3, 14, 12, 0, 0, 0, ctz), false},
+		{"3/14/2022", time.Time{}, time.Time{}, true},
+	}
+	for _, test := range tests {
+		since, err := ParseSince(test.value)
+		if (err != nil) != test.wantErr || !since.Equal(test.wantSince) {
+			t.Errorf("ParseSince(%q) = %s, %v; want %s", test.value, since, err, test.wantSince)
+		}
+		until, err := ParseUntil(test.value)
+		if (err != nil) != test.wantErr || !until.Equal(test.wantUntil) {
+			t.Errorf("ParseUntil(%q) = %s, %v; want %s", test.value, until, err, test.wantUntil)
+		}
+	}
+}
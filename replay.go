@@ -0,0 +1,181 @@
+package signups
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ReplayOptions select stored signups and the targets to redeliver them to.
+type ReplayOptions struct {
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+	// Status and Downstream select signups by their latest delivery status, e.g. Status "failed" and Downstream "email".
+	Status     string `json:"status"`
+	Downstream string `json:"downstream"`
+	// Targets are redelivered to. Empty means every target.
+	Targets []string `json:"targets"`
+	// DryRun reports what would be redelivered without sending anything.
+	DryRun bool `json:"dryRun"`
+}
+
+// ReplayResult is the outcome of redelivering a signup to a target.
+type ReplayResult struct {
+	Id     string `json:"id"`
+	Email  string `json:"email"`
+	Target string `json:"target"`
+	// Result is "delivered", "failed", "suppressed" (the address is on the suppression list), "skipped" (already delivered),
+	// "not attempted" (an earlier target failed), or "would deliver" (dry run).
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// String formats the result as a line of command output.
+func (r ReplayResult) String() string {
+	s := fmt.Sprintf("%s\t%s\t%s\t%s", r.Id, r.Email, r.Target, r.Result)
+	if r.Error != "" {
+		s += "\t" + r.Error
+	}
+	return s
+}
+
+// validate checks the options' statuses and targets.
+func (o ReplayOptions) validate() error {
+	switch o.Status {
+	case "", StatusDelivered, StatusFailed, StatusPending, StatusSuppressed, StatusBounced, StatusComplained:
+	default:
+		return fmt.Errorf("unknown status: '%s'", o.Status)
+	}
+	for _, target := range append([]string{o.Downstream}, o.Targets...) {
+		switch target {
+		case "", TargetGreenlight, TargetSlack, TargetEmail:
+		default:
+			return fmt.Errorf("unknown target: '%s'", target)
+		}
+	}
+	return nil
+}
+
+// Replay redelivers the stored signups selected by the options.
+// It is idempotent: targets a signup was already delivered to (or whose email was suppressed) are skipped, so nobody is emailed (or posted to Greenlight) twice,
+// and Slack messages are only posted to the destinations that failed. Emails that bounced or were marked as spam are skipped too; sending them again won't help.
+// Redelivered emails aren't sent to addresses on the suppression list.
+func Replay(ctx context.Context, opts ReplayOptions) ([]ReplayResult, error) {
+	err := opts.validate()
+	if err != nil {
+		return nil, err
+	}
+	targets := opts.Targets
+	if len(targets) == 0 {
+		targets = AllTargets
+	}
+
+	records, err := signupStore.Find(ctx, Query{Since: opts.Since, Until: opts.Until, Status: opts.Status, Target: opts.Downstream})
+	if err != nil {
+		return nil, err
+	}
+
+	results := []ReplayResult{}
+	for _, rec := range records {
+		result := func(target, outcome, errMsg string) {
+			results = append(results, ReplayResult{Id: rec.Id, Email: rec.Signup.Email, Target: target, Result: outcome, Error: errMsg})
+		}
+
+		pending := []string{}
+		for _, target := range AllTargets {
+			if !contains(targets, target) {
+				continue
+			}
+			switch rec.Status(target) {
+			case StatusDelivered, StatusSuppressed, StatusBounced, StatusComplained:
+				result(target, "skipped", "")
+				continue
+			}
+			pending = append(pending, target)
+		}
+		if len(pending) == 0 {
+			continue
+		}
+		if opts.DryRun {
+			for _, target := range pending {
+				result(target, "would deliver", "")
+			}
+			continue
+		}
+
+		attempts := len(rec.Deliveries)
+		// Errors are recorded per target on the record
+		// Redelivered emails aren't what the person just asked for, so they aren't sent to suppressed addresses
+		_ = deliver(ctx, rec, emailFollowUp, pending...)
+		err := signupStore.Save(ctx, rec)
+		if err != nil {
+			return results, fmt.Errorf("error saving signup %s: %w", rec.Id, err)
+		}
+
+		for _, target := range pending {
+			d, ok := latestSince(rec, target, attempts)
+			if !ok {
+				result(target, "not attempted", "")
+				continue
+			}
+			result(target, d.Status, d.Error)
+		}
+	}
+	return results, nil
+}
+
+// latestSince returns the latest delivery to the target recorded after the first n deliveries.
+func latestSince(rec *Record, target string, n int) (Delivery, bool) {
+	for i := len(rec.Deliveries) - 1; i >= n; i-- {
+		if rec.Deliveries[i].Target == target {
+			return rec.Deliveries[i], true
+		}
+	}
+	return Delivery{}, false
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleReplay redelivers stored signups. It requires the ADMIN_TOKEN.
+// The request body is JSON ReplayOptions and the response is a JSON array of ReplayResults.
+func HandleReplay(w http.ResponseWriter, r *http.Request) {
+	if !authorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var opts ReplayOptions
+	err := json.NewDecoder(r.Body).Decode(&opts)
+	if err != nil {
+		http.Error(w, "Error reading replay options: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := opts.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, err := Replay(r.Context(), opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(results)
+	if err != nil {
+		fmt.Printf("error writing replay response %s\n", err.Error())
+	}
+}
@@ -0,0 +1,152 @@
+package signups
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/operationspark/slack-session-signups/slack/slacktest"
+)
+
+func TestReplay(t *testing.T) {
+	var mu sync.Mutex
+	greenlightPosts := 0
+	greenlight := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		greenlightPosts++
+	}))
+	defer greenlight.Close()
+	slackSrv := slacktest.NewServer()
+	defer slackSrv.Close()
+
+	t.Setenv("GREENLIGHT_WEBHOOK_URL", greenlight.URL)
+	defer setVar(&SLACK_WEBHOOK_URL, slackSrv.WebhookURL("signups"))()
+	defer setVar(&SLACK_BOT_TOKEN, "")()
+	defer setStore(NewMemoryStore())()
+	ctx := context.Background()
+
+	// Greenlight was down, so Slack and email were never attempted
+	outage := newRecord(Signup{NameFirst: "Henri", NameLast: "Testaroni", Email: "henri@email.com"})
+	outage.record(TargetGreenlight, errors.New("502 Bad Gateway"))
+	// Everything was delivered
+	delivered := newRecord(Signup{NameFirst: "Yasiin", NameLast: "Bey", Email: "yasiin@email.com"})
+	for _, target := range AllTargets {
+		delivered.record(target, nil)
+	}
+	for _, rec := range []*Record{outage, delivered} {
+		if err := signupStore.Save(ctx, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Dry run
+	got, err := Replay(ctx, ReplayOptions{Targets: []string{TargetGreenlight, TargetSlack}, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ReplayResult{
+		{Id: outage.Id, Email: "henri@email.com", Target: TargetGreenlight, Result: "would deliver"},
+		{Id: outage.Id, Email: "henri@email.com", Target: TargetSlack, Result: "would deliver"},
+		{Id: delivered.Id, Email: "yasiin@email.com", Target: TargetGreenlight, Result: "skipped"},
+		{Id: delivered.Id, Email: "yasiin@email.com", Target: TargetSlack, Result: "skipped"},
+	}
+	if diff := cmp.Diff(sortResults(want), sortResults(got)); diff != "" {
+		t.Errorf("dry run mismatch (-want +got):\n%s", diff)
+	}
+	if greenlightPosts != 0 || len(slackSrv.Calls()) != 0 {
+		t.Fatalf("dry run should not deliver anything")
+	}
+
+	// Redeliver signups whose Greenlight delivery failed
+	got, err = Replay(ctx, ReplayOptions{Status: StatusFailed, Downstream: TargetGreenlight, Targets: []string{TargetGreenlight, TargetSlack}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []ReplayResult{
+		{Id: outage.Id, Email: "henri@email.com", Target: TargetGreenlight, Result: StatusDelivered},
+		{Id: outage.Id, Email: "henri@email.com", Target: TargetSlack, Result: StatusDelivered},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("replay mismatch (-want +got):\n%s", diff)
+	}
+	if greenlightPosts != 1 || len(slackSrv.Calls()) != 1 {
+		t.Errorf("want 1 Greenlight post and 1 Slack post, got %d and %d", greenlightPosts, len(slackSrv.Calls()))
+	}
+
+	// Replaying again is a no-op
+	got, err = Replay(ctx, ReplayOptions{Targets: []string{TargetGreenlight, TargetSlack}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range got {
+		if r.Result != "skipped" {
+			t.Errorf("want every target skipped on the second replay, got %+v", r)
+		}
+	}
+	if greenlightPosts != 1 || len(slackSrv.Calls()) != 1 {
+		t.Errorf("second replay should not deliver anything")
+	}
+}
+
+func TestHandleReplay(t *testing.T) {
+	defer setVar(&ADMIN_TOKEN, "admin-secret")()
+	defer setStore(NewMemoryStore())()
+
+	tests := []struct {
+		name       string
+		token      string
+		body       string
+		wantStatus int
+	}{
+		{"missing token", "", `{}`, http.StatusUnauthorized},
+		{"unknown target", "admin-secret", `{"targets": ["fax"]}`, http.StatusBadRequest},
+		{"unknown status", "admin-secret", `{"status": "lost"}`, http.StatusBadRequest},
+		{"dry run", "admin-secret", `{"since": "2022-03-01T00:00:00Z", "status": "failed", "downstream": "email", "dryRun": true}`, http.StatusOK},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/admin/replay", bytes.NewBufferString(test.body))
+		req.Header.Set("Authorization", "Bearer "+test.token)
+		w := httptest.NewRecorder()
+		HandleReplay(w, req)
+		if w.Code != test.wantStatus {
+			t.Errorf("%s: want status %d, got %d: %s", test.name, test.wantStatus, w.Code, w.Body)
+		}
+	}
+}
+
+// sortResults formats the results as sorted lines, for comparing results in any order.
+func sortResults(results []ReplayResult) []string {
+	lines := []string{}
+	for _, r := range results {
+		lines = append(lines, r.String())
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+func TestReplaySkipsBouncedEmail(t *testing.T) {
+	defer setStore(NewMemoryStore())()
+	rec := newRecord(Signup{NameFirst: "Henri", NameLast: "Testaroni", Email: "henri@email.com"})
+	rec.record(TargetGreenlight, nil)
+	rec.record(TargetSlack, nil)
+	rec.recordEmail("20220314170000.1@mail.operationspark.org", nil)
+	rec.Deliveries[2].Status = StatusBounced
+	if err := signupStore.Save(context.Background(), rec); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := Replay(context.Background(), ReplayOptions{Targets: []string{TargetEmail}, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Result != "skipped" {
+		t.Errorf("want the bounced email skipped, got %+v", results)
+	}
+}
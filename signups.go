@@ -3,11 +3,15 @@
 package signups
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"strings"
-	"text/template"
 	"time"
+
+	"github.com/operationspark/slack-session-signups/email"
+	"github.com/operationspark/slack-session-signups/slack"
 )
 
 type Signup struct {
@@ -22,11 +26,19 @@ type Signup struct {
 	Cohort           string    `json:"cohort" schema:"cohort"`
 	SessionId        string    `json:"sessionId" schema:"sessionId"`
 	Token            string    `json:"token" schema:"token"`
+	// UTM parameters and the page the signup form was on, for referral analytics.
+	UtmSource   string `json:"utmSource,omitempty" schema:"utmSource"`
+	UtmMedium   string `json:"utmMedium,omitempty" schema:"utmMedium"`
+	UtmCampaign string `json:"utmCampaign,omitempty" schema:"utmCampaign"`
+	LandingPage string `json:"landingPage,omitempty" schema:"landingPage"`
 }
 
 // Summary creates a string, summarizing a signup event.
 func (s *Signup) Summary() string {
 	sessionNote := fmt.Sprintf("%s %s has signed up for %s.", s.NameFirst, s.NameLast, s.Cohort)
+	if p := s.Program(); p.Id != DefaultProgramId {
+		sessionNote = fmt.Sprintf("%s %s has signed up for %s (%s).", s.NameFirst, s.NameLast, s.Cohort, p.Name)
+	}
 	if s.StartDateTime.IsZero() {
 		sessionNote = fmt.Sprintf("%s %s requested information on upcoming session times.", s.NameFirst, s.NameLast)
 	}
@@ -38,11 +50,39 @@ func (s *Signup) Summary() string {
 	return msg
 }
 
+// slackRouter sends notifications to channels picked by the SLACK_ROUTES rules, or the program's channel if none match.
+// The program's channel is posted to with the Web API when possible, so follow-up events can be threaded.
+func (s *Signup) slackRouter() slack.Router {
+	rules, err := slack.ParseRules(SLACK_ROUTES)
+	if err != nil {
+		fmt.Printf("ignoring SLACK_ROUTES: %s\n", err)
+	}
+	p := s.Program()
+	client := slackClient()
+	dest := p.SlackWebhookURL
+	if client != nil && p.SlackChannel != "" {
+		dest = p.SlackChannel
+	}
+	return slack.Router{Rules: rules, Default: []string{dest}, Client: client}
+}
+
+// slackAttributes describes the Signup for Slack routing rules.
+func (s *Signup) slackAttributes() slack.Attributes {
+	return slack.Attributes{
+		Cohort:    s.Cohort,
+		Program:   s.Program().Id,
+		Referrer:  s.Referrer,
+		NoSession: s.StartDateTime.IsZero(),
+	}
+}
+
 // WelcomeData takes a Signup and prepares data for use in the Welcome email template
 func (s *Signup) WelcomeData() (WelcomeValues, error) {
+	programName := s.Program().Name
 	if s.StartDateTime.IsZero() {
 		return WelcomeValues{
 			DisplayName: s.NameFirst,
+			ProgramName: programName,
 		}, nil
 	}
 	ctz, err := time.LoadLocation("America/Chicago")
@@ -51,20 +91,29 @@ func (s *Signup) WelcomeData() (WelcomeValues, error) {
 	}
 	return WelcomeValues{
 		DisplayName: s.NameFirst,
+		ProgramName: programName,
 		SessionDate: s.StartDateTime.Format("Monday, Jan 02"),
 		SessionTime: s.StartDateTime.In(ctz).Format("3:04 PM MST"),
 	}, nil
 }
 
-// html populates the Info Session Welcome email template with values from the Signup. It then writes the result to the io.Writer, w.
-func (s *Signup) html(w io.Writer) error {
-	t, err := template.New("welcome").Parse(InfoSessionHtml)
+// sendWelcome emails the Signup's program welcome email to the Signup and returns its Mailgun message ID.
+// The kind of email (emailConfirmation or emailFollowUp) decides whether it's sent to a suppressed address.
+func (s *Signup) sendWelcome(ctx context.Context, kind string) (string, error) {
+	err := checkSuppression(ctx, s.Email, kind)
 	if err != nil {
-		return err
+		return "", err
 	}
-	data, err := s.WelcomeData()
+	buf := new(bytes.Buffer)
+	err = s.html(buf)
 	if err != nil {
-		return err
+		return "", fmt.Errorf("error creating email HTML: %w", err)
 	}
-	return t.Execute(w, data)
+	p := s.Program()
+	return email.SendWelcome(ctx, s.Email, p.EmailSender, p.EmailSubject, buf.String(), unsubscribeHeaders(s.Email))
+}
+
+// html populates the Signup's program welcome email template with values from the Signup. It then writes the result to the io.Writer, w.
+func (s *Signup) html(w io.Writer) error {
+	return RenderEmail(w, s.Program().EmailTemplate, *s)
 }
@@ -0,0 +1,54 @@
+package slack
+
+// Block is a Block Kit layout block. Only the blocks and elements this service uses are supported.
+// https://api.slack.com/reference/block-kit/blocks
+type Block struct {
+	Type    string `json:"type"`
+	BlockId string `json:"block_id,omitempty"`
+	Text    *Text  `json:"text,omitempty"`
+	// Elements are Text objects in context blocks and Elements (buttons) in actions blocks.
+	Elements []interface{} `json:"elements,omitempty"`
+}
+
+// Text is a Block Kit text object.
+type Text struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Element is an interactive Block Kit block element, like a button.
+type Element struct {
+	Type     string `json:"type"`
+	Text     *Text  `json:"text,omitempty"`
+	ActionId string `json:"action_id,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Style    string `json:"style,omitempty"`
+}
+
+// Section creates a section block with mrkdwn text.
+func Section(text string) Block {
+	return Block{Type: "section", Text: &Text{Type: "mrkdwn", Text: text}}
+}
+
+// Context creates a context block with a mrkdwn element for each line of text.
+func Context(lines ...string) Block {
+	b := Block{Type: "context"}
+	for _, line := range lines {
+		b.Elements = append(b.Elements, Text{Type: "mrkdwn", Text: line})
+	}
+	return b
+}
+
+// Actions creates an actions block with the buttons.
+func Actions(blockId string, buttons ...Element) Block {
+	b := Block{Type: "actions", BlockId: blockId}
+	for _, button := range buttons {
+		b.Elements = append(b.Elements, button)
+	}
+	return b
+}
+
+// Button creates a button element. Clicking it sends a block_actions payload with the action ID and value to the app's interactivity URL.
+func Button(actionId, text, value string) Element {
+	return Element{Type: "button", Text: &Text{Type: "plain_text", Text: text}, ActionId: actionId, Value: value}
+}
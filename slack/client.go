@@ -0,0 +1,112 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// DefaultBaseURL is the Slack Web API root.
+const DefaultBaseURL = "https://slack.com/api"
+
+// Client posts and updates messages with the Slack Web API, using the OS Signups App's bot token.
+// Unlike Incoming Webhooks, messages posted by the Client can be edited and threaded.
+// https://api.slack.com/methods/chat.postMessage
+type Client struct {
+	Token      string
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// MessageRef identifies a message posted with the Web API.
+type MessageRef struct {
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+}
+
+// NewClient creates a Web API client with the bot token.
+func NewClient(token string) *Client {
+	return &Client{
+		Token:      token,
+		BaseURL:    DefaultBaseURL,
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type chatRequest struct {
+	Channel  string `json:"channel"`
+	TS       string `json:"ts,omitempty"`
+	ThreadTS string `json:"thread_ts,omitempty"`
+	Message
+}
+
+type chatResponse struct {
+	OK      bool   `json:"ok"`
+	Error   string `json:"error"`
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+}
+
+// PostMessage posts a message to the channel (chat.postMessage).
+func (c *Client) PostMessage(ctx context.Context, channel string, msg Message) (MessageRef, error) {
+	return c.call(ctx, "chat.postMessage", chatRequest{Channel: channel, Message: msg})
+}
+
+// Reply posts a message in the thread of the referenced message.
+func (c *Client) Reply(ctx context.Context, parent MessageRef, msg Message) (MessageRef, error) {
+	return c.call(ctx, "chat.postMessage", chatRequest{Channel: parent.Channel, ThreadTS: parent.TS, Message: msg})
+}
+
+// UpdateMessage replaces the content of the referenced message (chat.update).
+func (c *Client) UpdateMessage(ctx context.Context, ref MessageRef, msg Message) error {
+	_, err := c.call(ctx, "chat.update", chatRequest{Channel: ref.Channel, TS: ref.TS, Message: msg})
+	return err
+}
+
+func (c *Client) call(ctx context.Context, method string, req chatRequest) (MessageRef, error) {
+	if os.Getenv("DISABLE_SLACK") == "true" {
+		return MessageRef{}, nil
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return MessageRef{}, err
+	}
+
+	baseURL := c.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return MessageRef{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json; charset=utf-8")
+	httpReq.Header.Set("Authorization", "Bearer "+c.Token)
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return MessageRef{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return MessageRef{}, fmt.Errorf("error calling Slack %s: %s", method, resp.Status)
+	}
+
+	var chatResp chatResponse
+	err = json.NewDecoder(resp.Body).Decode(&chatResp)
+	if err != nil {
+		return MessageRef{}, fmt.Errorf("error reading Slack %s response: %w", method, err)
+	}
+	if !chatResp.OK {
+		return MessageRef{}, fmt.Errorf("error calling Slack %s: %s", method, chatResp.Error)
+	}
+	return MessageRef{Channel: chatResp.Channel, TS: chatResp.TS}, nil
+}
@@ -0,0 +1,87 @@
+package slack
+
+import (
+	"context"
+	"testing"
+
+	"github.com/operationspark/slack-session-signups/slack/slacktest"
+)
+
+func TestClientThreads(t *testing.T) {
+	srv := slacktest.NewServer()
+	defer srv.Close()
+
+	c := NewClient("xoxb-test")
+	c.BaseURL = srv.APIURL()
+	ctx := context.Background()
+
+	ref, err := c.PostMessage(ctx, "C0SIGNUPS", Message{Text: "Henri Testaroni has signed up"})
+	if err != nil {
+		t.Fatalf("PostMessage: %s", err)
+	}
+	if ref.Channel != "C0SIGNUPS" || ref.TS == "" {
+		t.Fatalf("PostMessage: unexpected ref %+v", ref)
+	}
+	if _, err := c.Reply(ctx, ref, Message{Text: "Cancelled"}); err != nil {
+		t.Fatalf("Reply: %s", err)
+	}
+	if err := c.UpdateMessage(ctx, ref, Message{Text: "Henri Testaroni has signed up\nStatus: cancelled"}); err != nil {
+		t.Fatalf("UpdateMessage: %s", err)
+	}
+
+	calls := srv.Calls()
+	if len(calls) != 3 {
+		t.Fatalf("want 3 calls, got %d", len(calls))
+	}
+	if calls[0].Token != "xoxb-test" {
+		t.Errorf("want bot token sent, got %q", calls[0].Token)
+	}
+	if calls[1].Method != "chat.postMessage" || calls[1].ThreadTS != ref.TS {
+		t.Errorf("Reply should post in thread %s, got %+v", ref.TS, calls[1])
+	}
+	if calls[2].Method != "chat.update" || calls[2].TS != ref.TS {
+		t.Errorf("UpdateMessage should update %s, got %+v", ref.TS, calls[2])
+	}
+}
+
+func TestClientError(t *testing.T) {
+	srv := slacktest.NewServer()
+	defer srv.Close()
+
+	c := NewClient("")
+	c.BaseURL = srv.APIURL()
+	_, err := c.PostMessage(context.Background(), "C0SIGNUPS", Message{Text: "hi"})
+	if err == nil || err.Error() != "error calling Slack chat.postMessage: not_authed" {
+		t.Errorf("want not_authed error, got %v", err)
+	}
+}
+
+func TestRouterSend(t *testing.T) {
+	srv := slacktest.NewServer()
+	defer srv.Close()
+
+	c := NewClient("xoxb-test")
+	c.BaseURL = srv.APIURL()
+	router := Router{Default: []string{srv.WebhookURL("signups"), "C0SIGNUPS"}, Client: c}
+
+	refs, err := router.Send(context.Background(), Attributes{}, Message{Text: "hi"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(refs) != 1 || refs[0].Channel != "C0SIGNUPS" {
+		t.Errorf("want a ref for the channel post only, got %+v", refs)
+	}
+	if got := len(srv.Calls()); got != 2 {
+		t.Errorf("want webhook and channel posts, got %d calls", got)
+	}
+
+	router.Client = nil
+	if _, err := router.Send(context.Background(), Attributes{}, Message{Text: "hi"}); err == nil {
+		t.Errorf("want error posting to a channel without a Client")
+	}
+	// The other destinations are still posted to
+	_, sent, err := router.SendTo(context.Background(), router.Default, Message{Text: "hi"})
+	if err == nil || len(sent) != 1 || sent[0] != srv.WebhookURL("signups") {
+		t.Errorf("want only the webhook posted to, got %v (%v)", sent, err)
+	}
+}
@@ -0,0 +1,160 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// Attributes describe the signup a notification is about. Rules match against them.
+type Attributes struct {
+	Cohort   string
+	Program  string
+	Referrer string
+	// NoSession is true when the person requested information instead of picking a session time.
+	NoSession bool
+}
+
+// Rule sends notifications matching all of its conditions to its destinations.
+// Empty conditions match anything. Cohort, Program, and Referrer are case-insensitive and support wildcards ("is-mar-*").
+type Rule struct {
+	Name      string `json:"name"`
+	Cohort    string `json:"cohort,omitempty"`
+	Program   string `json:"program,omitempty"`
+	Referrer  string `json:"referrer,omitempty"`
+	NoSession bool   `json:"noSession,omitempty"`
+	// Destinations are Incoming Webhook URLs or, when the Router has a Client, channel IDs.
+	Destinations []string `json:"destinations"`
+}
+
+// Matches reports whether the attributes meet all of the rule's conditions.
+func (r Rule) Matches(a Attributes) bool {
+	if r.NoSession && !a.NoSession {
+		return false
+	}
+	return matchPattern(r.Cohort, a.Cohort) &&
+		matchPattern(r.Program, a.Program) &&
+		matchPattern(r.Referrer, a.Referrer)
+}
+
+// Router picks where a notification is posted.
+type Router struct {
+	Rules []Rule
+	// Default destinations are used when no rule matches.
+	Default []string
+	// Client posts to channel ID destinations. Without a Client, only webhook URLs can be posted to.
+	Client *Client
+}
+
+// ParseRules parses a JSON array of routing rules, e.g.
+//
+//	[{"name": "outreach", "noSession": true, "destinations": ["https://hooks.slack.com/services/..."]}]
+//
+// An empty string has no rules.
+func ParseRules(data string) ([]Rule, error) {
+	if strings.TrimSpace(data) == "" {
+		return nil, nil
+	}
+	var rules []Rule
+	err := json.Unmarshal([]byte(data), &rules)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Slack routing rules: %w", err)
+	}
+	for i, r := range rules {
+		if len(r.Destinations) == 0 {
+			return nil, fmt.Errorf("Slack routing rule %d (%q) has no destinations", i, r.Name)
+		}
+	}
+	return rules, nil
+}
+
+// Destinations returns the destinations of every rule matching the attributes, without duplicates.
+// If no rule matches, it returns the default destinations.
+func (rt Router) Destinations(a Attributes) []string {
+	dests := []string{}
+	for _, r := range rt.Rules {
+		if r.Matches(a) {
+			dests = appendUnique(dests, r.Destinations...)
+		}
+	}
+	if len(dests) == 0 {
+		return appendUnique(dests, rt.Default...)
+	}
+	return dests
+}
+
+// Send posts the message to every destination for the attributes and returns references to the messages posted to channels.
+// Messages posted to webhook URLs cannot be referenced later.
+// It attempts every destination before returning the first error encountered.
+func (rt Router) Send(ctx context.Context, a Attributes, msg Message) ([]MessageRef, error) {
+	refs, _, err := rt.SendTo(ctx, rt.Destinations(a), msg)
+	return refs, err
+}
+
+// SendTo posts the message to the destinations, like Send, and also returns the destinations it was posted to,
+// so a failed post can be retried without posting to the others again.
+func (rt Router) SendTo(ctx context.Context, dests []string, msg Message) ([]MessageRef, []string, error) {
+	if os.Getenv("DISABLE_SLACK") == "true" {
+		return nil, nil, nil
+	}
+	var refs []MessageRef
+	var sent []string
+	var firstErr error
+	for _, dest := range dests {
+		var err error
+		switch {
+		case isWebhookURL(dest):
+			err = sendWebhook(ctx, dest, msg)
+		case rt.Client == nil:
+			err = fmt.Errorf("cannot post to Slack channel %s without a bot token", dest)
+		default:
+			var ref MessageRef
+			ref, err = rt.Client.PostMessage(ctx, dest, msg)
+			if err == nil {
+				refs = append(refs, ref)
+			}
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		sent = append(sent, dest)
+	}
+	return refs, sent, firstErr
+}
+
+func isWebhookURL(dest string) bool {
+	return strings.HasPrefix(dest, "https://") || strings.HasPrefix(dest, "http://")
+}
+
+func matchPattern(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, err := path.Match(strings.ToLower(pattern), strings.ToLower(value))
+	return err == nil && ok
+}
+
+func appendUnique(list []string, items ...string) []string {
+	for _, item := range items {
+		if item == "" {
+			continue
+		}
+		found := false
+		for _, existing := range list {
+			if existing == item {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,76 @@
+package slack
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRouterDestinations(t *testing.T) {
+	rules, err := ParseRules(`[
+		{"name": "outreach", "noSession": true, "destinations": ["https://hooks/outreach"]},
+		{"name": "workshops", "program": "workshop", "destinations": ["https://hooks/workshops"]},
+		{"name": "march", "cohort": "is-mar-*", "destinations": ["https://hooks/march", "https://hooks/signups"]},
+		{"name": "instagram", "referrer": "Instagram", "destinations": ["https://hooks/marketing"]}
+	]`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	router := Router{Rules: rules, Default: []string{"https://hooks/signups"}}
+
+	tests := []struct {
+		name  string
+		attrs Attributes
+		want  []string
+	}{
+		{
+			name:  "no rule matches, use default",
+			attrs: Attributes{Cohort: "is-feb-28-22-12pm", Program: "info-session"},
+			want:  []string{"https://hooks/signups"},
+		},
+		{
+			name:  "requested information",
+			attrs: Attributes{Program: "info-session", NoSession: true},
+			want:  []string{"https://hooks/outreach"},
+		},
+		{
+			name:  "cohort wildcard",
+			attrs: Attributes{Cohort: "is-mar-14-22-12pm", Program: "info-session"},
+			want:  []string{"https://hooks/march", "https://hooks/signups"},
+		},
+		{
+			name:  "multiple rules match without duplicates",
+			attrs: Attributes{Cohort: "IS-MAR-14-22-12PM", Program: "workshop", Referrer: "instagram"},
+			want:  []string{"https://hooks/workshops", "https://hooks/march", "https://hooks/signups", "https://hooks/marketing"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := router.Destinations(test.attrs)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("router.Destinations() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"empty", "", false},
+		{"valid", `[{"name": "outreach", "noSession": true, "destinations": ["https://hooks/outreach"]}]`, false},
+		{"malformed", `{"name": "outreach"`, true},
+		{"no destinations", `[{"name": "outreach", "noSession": true}]`, true},
+	}
+
+	for _, test := range tests {
+		_, err := ParseRules(test.data)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: ParseRules() error = %v, wantErr %v", test.name, err, test.wantErr)
+		}
+	}
+}
@@ -2,20 +2,31 @@ package slack
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
+	"time"
 )
 
+// responseClient posts interaction responses. Slack's response_url can be slow, so it isn't waited on forever.
+var responseClient = &http.Client{Timeout: 10 * time.Second}
+
 type Message struct {
-	Text string `json:"text"`
+	// Text is the message, or the notification fallback when the message has Blocks.
+	Text   string  `json:"text"`
+	Blocks []Block `json:"blocks,omitempty"`
 }
 
 // SendWebhook POSTs a message to the OS Signups Slack App webhook.
 // This incoming webhook posts a message to the #signups channel.
 // https://api.slack.com/apps/A0338E8UFFV/incoming-webhooks
 func SendWebhook(url string, msg Message) error {
+	return sendWebhook(context.Background(), url, msg)
+}
+
+func sendWebhook(ctx context.Context, url string, msg Message) error {
 	if os.Getenv("DISABLE_SLACK") == "true" {
 		return nil
 	}
@@ -24,13 +35,62 @@ func SendWebhook(url string, msg Message) error {
 		return err
 	}
 
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(body))
 	if err != nil {
 		return err
 	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
 		return fmt.Errorf("error sending Slack message: %s", resp.Status)
 	}
 
 	return nil
 }
+
+// Respond replaces the message an interaction came from using the interaction's response_url.
+// https://api.slack.com/interactivity/handling#message_responses
+func Respond(ctx context.Context, responseURL string, msg Message) error {
+	if os.Getenv("DISABLE_SLACK") == "true" {
+		return nil
+	}
+	body, err := json.Marshal(struct {
+		ReplaceOriginal bool `json:"replace_original"`
+		Message
+	}{true, msg})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, responseURL, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := responseClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("error responding to Slack interaction: %s", resp.Status)
+	}
+	return nil
+}
+
+// CommandResponse is a reply to a slash command.
+// https://api.slack.com/interactivity/slash-commands#responding_to_commands
+type CommandResponse struct {
+	// ResponseType is "ephemeral" (only visible to the user who ran the command) or "in_channel".
+	ResponseType string `json:"response_type"`
+	Message
+}
+
+// Ephemeral creates a slash command reply only the user who ran the command can see.
+func Ephemeral(msg Message) CommandResponse {
+	return CommandResponse{ResponseType: "ephemeral", Message: msg}
+}
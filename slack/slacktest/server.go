@@ -0,0 +1,162 @@
+// Package slacktest provides a fake Slack server for testing code that posts Incoming Webhooks
+// or calls the Web API's chat methods.
+package slacktest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// Call is a request received by the fake Slack server.
+type Call struct {
+	// Method is the Web API method ("chat.postMessage", "chat.update"), "webhook", or "response".
+	Method string
+	// Webhook is the name of the Incoming Webhook posted to.
+	Webhook  string
+	Token    string
+	Channel  string
+	TS       string
+	ThreadTS string
+	Text     string
+	Body     []byte
+}
+
+// Server is a fake Slack server that records every message it receives.
+// Incoming Webhooks are served at WebhookURL(name), interaction response URLs at ResponseURL(name), and the Web API at APIURL().
+type Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	calls   []Call
+	nextTS  int
+	failure http.Handler
+}
+
+// NewServer starts a fake Slack server. The caller should call Close when finished.
+func NewServer() *Server {
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// WebhookURL returns the URL of a fake Incoming Webhook.
+func (s *Server) WebhookURL(name string) string {
+	return s.URL + "/webhook/" + name
+}
+
+// ResponseURL returns the URL of a fake interaction response_url.
+func (s *Server) ResponseURL(name string) string {
+	return s.URL + "/response/" + name
+}
+
+// APIURL returns the base URL of the fake Web API, for use as slack.Client.BaseURL.
+func (s *Server) APIURL() string {
+	return s.URL + "/api"
+}
+
+// FailWith makes the server answer every request with h, e.g. a 500 or a malformed response, after recording it.
+// FailWith(nil) restores normal responses.
+func (s *Server) FailWith(h http.Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failure = h
+}
+
+// Calls returns every request received so far.
+func (s *Server) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Call{}, s.calls...)
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		Channel  string `json:"channel"`
+		TS       string `json:"ts"`
+		ThreadTS string `json:"thread_ts"`
+		Text     string `json:"text"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "invalid_payload", http.StatusBadRequest)
+		return
+	}
+
+	call := Call{
+		Token:    strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer")),
+		Channel:  req.Channel,
+		TS:       req.TS,
+		ThreadTS: req.ThreadTS,
+		Text:     req.Text,
+		Body:     body,
+	}
+
+	s.mu.Lock()
+	failure := s.failure
+	s.mu.Unlock()
+	if failure != nil {
+		call.Method = strings.TrimPrefix(r.URL.Path, "/api/")
+		if strings.HasPrefix(r.URL.Path, "/webhook/") {
+			call.Method = "webhook"
+			call.Webhook = strings.TrimPrefix(r.URL.Path, "/webhook/")
+		}
+		s.record(call)
+		failure.ServeHTTP(w, r)
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/webhook/"):
+		call.Method = "webhook"
+		call.Webhook = strings.TrimPrefix(r.URL.Path, "/webhook/")
+		s.record(call)
+		w.Write([]byte("ok"))
+
+	case strings.HasPrefix(r.URL.Path, "/response/"):
+		call.Method = "response"
+		s.record(call)
+		w.Write([]byte("ok"))
+
+	case r.URL.Path == "/api/chat.postMessage", r.URL.Path == "/api/chat.update":
+		call.Method = strings.TrimPrefix(r.URL.Path, "/api/")
+		if call.Token == "" {
+			writeJSON(w, map[string]interface{}{"ok": false, "error": "not_authed"})
+			return
+		}
+		if call.Method == "chat.postMessage" {
+			call.TS = s.newTS()
+		}
+		s.record(call)
+		writeJSON(w, map[string]interface{}{"ok": true, "channel": call.Channel, "ts": call.TS})
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) record(c Call) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, c)
+}
+
+func (s *Server) newTS() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextTS++
+	return fmt.Sprintf("1650000000.%06d", s.nextTS)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
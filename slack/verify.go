@@ -0,0 +1,60 @@
+package slack
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrInvalidSignature is returned by VerifyRequest when a request was not signed by Slack.
+var ErrInvalidSignature = errors.New("invalid Slack request signature")
+
+// maxRequestAge limits how old a signed request can be, to prevent replays.
+const maxRequestAge = 5 * time.Minute
+
+// maxRequestBytes limits the size of request bodies. Slack's interaction payloads are well under it.
+const maxRequestBytes = 1 << 20
+
+// VerifyRequest checks that the request was signed by Slack with the app's signing secret and returns the request body.
+// The request body is replaced so it can be read (or parsed as a form) again.
+// https://api.slack.com/authentication/verifying-requests-from-slack
+func VerifyRequest(r *http.Request, signingSecret string, now time.Time) ([]byte, error) {
+	if signingSecret == "" {
+		return nil, errors.New("Slack signing secret not set")
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxRequestBytes))
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	ts := r.Header.Get("X-Slack-Request-Timestamp")
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	age := now.Sub(time.Unix(unix, 0))
+	if age > maxRequestAge || age < -maxRequestAge {
+		return nil, ErrInvalidSignature
+	}
+
+	want := Sign(signingSecret, ts, body)
+	if !hmac.Equal([]byte(want), []byte(r.Header.Get("X-Slack-Signature"))) {
+		return nil, ErrInvalidSignature
+	}
+	return body, nil
+}
+
+// Sign computes the X-Slack-Signature header value for a request body sent at the timestamp.
+func Sign(signingSecret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(signingSecret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
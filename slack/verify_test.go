@@ -0,0 +1,54 @@
+package slack
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyRequest(t *testing.T) {
+	now := time.Unix(1650000000, 0)
+	body := "token=abc&command=%2Fsignups&text=today"
+
+	tests := []struct {
+		name      string
+		timestamp time.Time
+		signature string
+		wantErr   bool
+	}{
+		{"valid", now, Sign("secret", "1650000000", []byte(body)), false},
+		{"wrong secret", now, Sign("not-the-secret", "1650000000", []byte(body)), true},
+		{"stale timestamp", now.Add(-10 * time.Minute), "", true},
+		{"missing signature", now, "", true},
+	}
+
+	for _, test := range tests {
+		ts := strconv.FormatInt(test.timestamp.Unix(), 10)
+		sig := test.signature
+		if sig == "" && test.name == "stale timestamp" {
+			sig = Sign("secret", ts, []byte(body))
+		}
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		req.Header.Set("X-Slack-Request-Timestamp", ts)
+		req.Header.Set("X-Slack-Signature", sig)
+
+		got, err := VerifyRequest(req, "secret", now)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: VerifyRequest() error = %v, wantErr %v", test.name, err, test.wantErr)
+		}
+		if err == nil && string(got) != body {
+			t.Errorf("%s: VerifyRequest() should return the body, got %q", test.name, got)
+		}
+	}
+
+	// Bodies over the limit aren't read into memory
+	big := strings.Repeat("a", maxRequestBytes+1)
+	req := httptest.NewRequest("POST", "/", strings.NewReader(big))
+	req.Header.Set("X-Slack-Request-Timestamp", "1650000000")
+	req.Header.Set("X-Slack-Signature", Sign("secret", "1650000000", []byte(big)))
+	if _, err := VerifyRequest(req, "secret", now); err == nil {
+		t.Error("VerifyRequest() should reject a body over the limit")
+	}
+}
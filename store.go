@@ -0,0 +1,366 @@
+package signups
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/operationspark/slack-session-signups/email"
+	"github.com/operationspark/slack-session-signups/slack"
+)
+
+// ErrNotFound is returned by a Store when a signup record does not exist.
+var ErrNotFound = errors.New("signup not found")
+
+// Record is a signup received by the service.
+type Record struct {
+	Id         string
+	Signup     Signup
+	ReceivedAt time.Time
+	// RawPayload is the request body the Signup was parsed from, with its ContentType.
+	RawPayload  []byte
+	ContentType string
+	SourceIP    string
+	UserAgent   string
+	// SlackMessages are the Web API messages posted about the signup. Follow-up events are threaded under them.
+	SlackMessages []slack.MessageRef
+	// AssignedTo is the Slack user ID of the staff member following up with the signup.
+	AssignedTo string
+	Activity   []Activity
+	// Deliveries are the results of sending the signup downstream, oldest first.
+	Deliveries []Delivery
+	// History is the person's earlier signups, oldest first, found when the signup was received.
+	History []PriorSignup
+	// EmailCheck is what checking the signup's email address found when the signup was received.
+	EmailCheck email.Check
+}
+
+// Downstream delivery targets.
+const (
+	TargetGreenlight = "greenlight"
+	TargetSlack      = "slack"
+	TargetEmail      = "email"
+)
+
+// AllTargets are the delivery targets in the order a signup is delivered to them.
+var AllTargets = []string{TargetGreenlight, TargetSlack, TargetEmail}
+
+// Delivery statuses.
+const (
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+	// StatusPending means delivery to the target hasn't been attempted, e.g. because an earlier target failed.
+	StatusPending = "pending"
+	// StatusSuppressed means the welcome email was skipped because the person was already emailed (see DUPLICATE_EMAIL_WINDOW),
+	// or a redelivered email was skipped because the address is on the suppression list.
+	StatusSuppressed = "suppressed"
+	// StatusBounced and StatusComplained mean the welcome email was sent, but Mailgun reported a hard bounce or a spam complaint.
+	StatusBounced    = "bounced"
+	StatusComplained = "complained"
+)
+
+// Delivery is the result of sending a signup to a downstream target.
+type Delivery struct {
+	Target string    `json:"target"`
+	Status string    `json:"status"`
+	Error  string    `json:"error,omitempty"`
+	At     time.Time `json:"at"`
+	// MessageId is the Mailgun message ID of a sent email. Mailgun's delivery events are matched to the signup by it.
+	MessageId string `json:"messageId,omitempty"`
+	// Event is the latest Mailgun event for the email, e.g. "delivered", "opened", or "clicked".
+	Event string `json:"event,omitempty"`
+	// Destinations are the Slack destinations the message was posted to, as slackDestinationKeys.
+	Destinations []string `json:"-"`
+}
+
+// record adds the result of sending the signup to the target.
+func (r *Record) record(target string, err error) {
+	d := Delivery{Target: target, Status: StatusDelivered, At: time.Now().UTC()}
+	if err != nil {
+		d.Status = StatusFailed
+		d.Error = err.Error()
+	}
+	r.Deliveries = append(r.Deliveries, d)
+}
+
+// recordEmail adds the result of sending a welcome email, with its Mailgun message ID.
+func (r *Record) recordEmail(messageId string, err error) {
+	r.record(TargetEmail, err)
+	r.Deliveries[len(r.Deliveries)-1].MessageId = messageId
+}
+
+// recordSlack adds the result of posting the signup to Slack, with the destinations it was posted to.
+func (r *Record) recordSlack(sent []string, err error) {
+	r.record(TargetSlack, err)
+	d := &r.Deliveries[len(r.Deliveries)-1]
+	for _, dest := range sent {
+		d.Destinations = append(d.Destinations, slackDestinationKey(dest))
+	}
+}
+
+// postedToSlack reports whether the signup has been posted to the Slack destination.
+func (r *Record) postedToSlack(dest string) bool {
+	key := slackDestinationKey(dest)
+	for _, d := range r.Deliveries {
+		if d.Target == TargetSlack && contains(d.Destinations, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// slackDestinationKey identifies a Slack destination in stored deliveries. Webhook URLs are secrets, so they're hashed.
+func slackDestinationKey(dest string) string {
+	if !strings.HasPrefix(dest, "https://") && !strings.HasPrefix(dest, "http://") {
+		return dest
+	}
+	sum := sha256.Sum256([]byte(dest))
+	return "webhook:" + hex.EncodeToString(sum[:8])
+}
+
+// Delivery returns the latest delivery to the target, if the signup has been sent there.
+func (r *Record) Delivery(target string) (Delivery, bool) {
+	for i := len(r.Deliveries) - 1; i >= 0; i-- {
+		if r.Deliveries[i].Target == target {
+			return r.Deliveries[i], true
+		}
+	}
+	return Delivery{}, false
+}
+
+// Status returns the status of the latest delivery to the target, or StatusPending if it hasn't been attempted.
+func (r *Record) Status(target string) string {
+	if d, ok := r.Delivery(target); ok {
+		return d.Status
+	}
+	return StatusPending
+}
+
+// Failed returns the targets whose latest delivery failed.
+func (r *Record) Failed() []string {
+	failed := []string{}
+	for _, target := range AllTargets {
+		if r.Status(target) == StatusFailed {
+			failed = append(failed, target)
+		}
+	}
+	return failed
+}
+
+// sentMessage reports whether the email with the Mailgun message ID was sent to the signup.
+func (r *Record) sentMessage(messageId string) bool {
+	for _, d := range r.Deliveries {
+		if d.MessageId == messageId {
+			return true
+		}
+	}
+	return false
+}
+
+// Activity is something that happened to a signup after it was received, like a follow-up event or a staff action in Slack.
+type Activity struct {
+	// Action describes what happened, e.g. "cancelled" or "marked contacted".
+	Action string
+	Note   string
+	// UserId is the Slack user who took the action. It is empty for events from other services.
+	UserId string
+	At     time.Time
+}
+
+// Query selects signup records. Empty fields match any record.
+type Query struct {
+	Email     string
+	SessionId string
+	Cohort    string
+	// ProgramId selects signups for the program. Signups without a known program are for the DefaultProgramId.
+	ProgramId string
+	// Applicant selects the signups from a person by normalized email or phone.
+	Applicant Applicant
+	// MessageId selects the signup sent the email with the Mailgun message ID.
+	MessageId string
+	// Since and Until select records received in [Since, Until).
+	Since time.Time
+	Until time.Time
+	// Status selects records whose latest delivery to Target (or any target, if Target is empty) has the status.
+	Status string
+	Target string
+}
+
+// Matches reports whether the record meets all of the query's conditions.
+func (q Query) Matches(r *Record) bool {
+	if q.Email != "" && !strings.EqualFold(q.Email, r.Signup.Email) {
+		return false
+	}
+	if q.SessionId != "" && q.SessionId != r.Signup.SessionId {
+		return false
+	}
+	if q.Cohort != "" && !strings.EqualFold(q.Cohort, r.Signup.Cohort) {
+		return false
+	}
+	if q.ProgramId != "" && q.ProgramId != r.Signup.Program().Id {
+		return false
+	}
+	if (q.Applicant != Applicant{}) && !q.Applicant.Matches(&r.Signup) {
+		return false
+	}
+	if q.MessageId != "" && !r.sentMessage(q.MessageId) {
+		return false
+	}
+	if !q.Since.IsZero() && r.ReceivedAt.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !r.ReceivedAt.Before(q.Until) {
+		return false
+	}
+	return q.matchesDeliveries(r)
+}
+
+// matchesDeliveries reports whether the record's delivery status meets the query's Status and Target conditions.
+func (q Query) matchesDeliveries(r *Record) bool {
+	if q.Status == "" {
+		return true
+	}
+	targets := AllTargets
+	if q.Target != "" {
+		targets = []string{q.Target}
+	}
+	for _, target := range targets {
+		if r.Status(target) == q.Status {
+			return true
+		}
+	}
+	return false
+}
+
+// Store persists signup records and the email suppression list.
+type Store interface {
+	// Save creates the record, or replaces an existing record with the same Id.
+	Save(ctx context.Context, r *Record) error
+	// Get returns the record with the Id, or ErrNotFound.
+	Get(ctx context.Context, id string) (*Record, error)
+	// Find returns the records matching the query, oldest first.
+	Find(ctx context.Context, q Query) ([]*Record, error)
+	// Update calls fn with the record with the Id and saves the changes fn makes, without another Update or Save in between.
+	// Use it to change a record that could be changed concurrently. If fn returns an error, nothing is saved and Update returns it.
+	// It returns the updated record, or ErrNotFound.
+	Update(ctx context.Context, id string, fn func(r *Record) error) (*Record, error)
+
+	// Suppress adds the email address to the suppression list, or replaces its suppression.
+	Suppress(ctx context.Context, s *Suppression) error
+	// GetSuppression returns the suppression of the (normalized) email address, or ErrNotFound.
+	GetSuppression(ctx context.Context, email string) (*Suppression, error)
+}
+
+// SIGNUP_DB_PATH is the SQLite database file signups are stored in. Without it, only the newest signups are kept in memory.
+// The file is local to the instance: on Cloud Functions each instance has its own, and it's lost when the instance shuts down.
+var SIGNUP_DB_PATH = os.Getenv("SIGNUP_DB_PATH")
+
+// signupStore records every signup the service receives.
+var signupStore Store = openStore()
+
+// openStore opens the SIGNUP_DB_PATH database, falling back to memory so signups are still delivered if it can't be opened.
+// The fallback is logged loudly, since stored signups, suppressions, and rate limits don't survive a restart.
+func openStore() Store {
+	if SIGNUP_DB_PATH == "" {
+		fmt.Printf("WARNING: SIGNUP_DB_PATH is not set. Keeping the newest %d signups in memory; they are lost on restart.\n", maxMemoryRecords)
+		return NewMemoryStore()
+	}
+	s, err := OpenSQLiteStore(SIGNUP_DB_PATH)
+	if err != nil {
+		fmt.Printf("ERROR: opening signup database %s failed, keeping the newest %d signups in memory: %s\n", SIGNUP_DB_PATH, maxMemoryRecords, err)
+		return NewMemoryStore()
+	}
+	return s
+}
+
+// newRecord creates a record for a signup received now.
+func newRecord(s Signup) *Record {
+	return &Record{Id: newId(), Signup: s, ReceivedAt: time.Now().UTC()}
+}
+
+// newRequestRecord creates a record for a signup parsed from the request body, raw.
+func newRequestRecord(s Signup, r *http.Request, raw []byte) *Record {
+	rec := newRecord(s)
+	rec.RawPayload = raw
+	rec.ContentType = r.Header.Get("Content-Type")
+	rec.SourceIP = sourceIP(r)
+	rec.UserAgent = r.UserAgent()
+	return rec
+}
+
+// trustedProxies are the proxies in front of the service, from TRUSTED_PROXIES: comma-separated CIDRs, e.g. an external
+// HTTPS load balancer's 35.191.0.0/16,130.211.0.0/22. X-Forwarded-For is only read from requests they send.
+var trustedProxies = parseCIDRs("TRUSTED_PROXIES", os.Getenv("TRUSTED_PROXIES"))
+
+// behindGoogleFrontEnd is whether the service is running on Cloud Functions or Cloud Run, where every request comes through
+// Google's front end. It appends the address it received the request from to X-Forwarded-For.
+var behindGoogleFrontEnd = os.Getenv("K_SERVICE") != "" || os.Getenv("FUNCTION_TARGET") != ""
+
+// sourceIP returns the client IP address of the request. X-Forwarded-For is only used when the request came from Google's front end
+// or a trusted proxy, since anyone else can send anything in it. Proxies append the address they received the request from, and
+// clients can send anything before it, so the right-most address that isn't a trusted proxy is used. Otherwise it's the RemoteAddr.
+func sourceIP(r *http.Request) string {
+	client, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		client = r.RemoteAddr
+	}
+	if peer := net.ParseIP(client); !behindGoogleFrontEnd && (peer == nil || !containsIP(trustedProxies, peer)) {
+		return client
+	}
+
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			break
+		}
+		client = ip.String()
+		if !containsIP(trustedProxies, ip) {
+			break
+		}
+	}
+	return client
+}
+
+// parseCIDRs parses the comma-separated CIDRs in the setting named name, ignoring invalid CIDRs.
+func parseCIDRs(name, cidrs string) []*net.IPNet {
+	nets := []*net.IPNet{}
+	for _, cidr := range strings.Split(cidrs, ",") {
+		if cidr = strings.TrimSpace(cidr); cidr == "" {
+			continue
+		}
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			fmt.Printf("ignoring invalid %s CIDR %q\n", name, cidr)
+			continue
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// newId returns a random, URL-safe record ID.
+func newId() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
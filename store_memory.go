@@ -0,0 +1,171 @@
+package signups
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/operationspark/slack-session-signups/slack"
+)
+
+// MemoryStore is a Store that keeps records in memory, for tests and local development.
+// Records are lost when the process exits, and only the newest maxMemoryRecords are kept.
+type MemoryStore struct {
+	mu           sync.RWMutex
+	records      map[string]Record
+	suppressions map[string]Suppression
+	buckets      map[string]tokenBucket
+}
+
+// tokenBucket is a rate limit bucket's tokens when it was last updated.
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	limit   Limit
+}
+
+// maxBuckets is how many rate limit buckets a MemoryStore keeps. Full buckets are dropped first, then the least recently used.
+const maxBuckets = 10000
+
+// maxMemoryRecords is how many records a MemoryStore keeps before dropping the oldest, so it can't grow without limit.
+const maxMemoryRecords = 1000
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: map[string]Record{}, suppressions: map[string]Suppression{}, buckets: map[string]tokenBucket{}}
+}
+
+func (m *MemoryStore) Save(ctx context.Context, r *Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.records[r.Id]; !ok && len(m.records) >= maxMemoryRecords {
+		m.dropOldestRecord()
+	}
+	m.records[r.Id] = copyRecord(r)
+	return nil
+}
+
+// dropOldestRecord deletes the record received first.
+func (m *MemoryStore) dropOldestRecord() {
+	oldest := ""
+	for id, r := range m.records {
+		if oldest == "" || r.ReceivedAt.Before(m.records[oldest].ReceivedAt) {
+			oldest = id
+		}
+	}
+	delete(m.records, oldest)
+}
+
+func (m *MemoryStore) Get(ctx context.Context, id string) (*Record, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	r, ok := m.records[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := copyRecord(&r)
+	return &c, nil
+}
+
+func (m *MemoryStore) Update(ctx context.Context, id string, fn func(r *Record) error) (*Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.records[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := copyRecord(&r)
+	if err := fn(&c); err != nil {
+		return nil, err
+	}
+	m.records[id] = copyRecord(&c)
+	return &c, nil
+}
+
+func (m *MemoryStore) Find(ctx context.Context, q Query) ([]*Record, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	found := []*Record{}
+	for _, r := range m.records {
+		r := r
+		if q.Matches(&r) {
+			c := copyRecord(&r)
+			found = append(found, &c)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].ReceivedAt.Before(found[j].ReceivedAt)
+	})
+	return found, nil
+}
+
+func (m *MemoryStore) Suppress(ctx context.Context, s *Suppression) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.suppressions[s.Email] = *s
+	return nil
+}
+
+func (m *MemoryStore) GetSuppression(ctx context.Context, email string) (*Suppression, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.suppressions[email]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &s, nil
+}
+
+func (m *MemoryStore) Take(ctx context.Context, key string, l Limit, now time.Time) (bool, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tokens := l.Burst
+	if b, ok := m.buckets[key]; ok {
+		tokens = l.refill(b.tokens, b.updated, now)
+	}
+	if tokens < 1 {
+		return false, l.wait(tokens), nil
+	}
+
+	if _, ok := m.buckets[key]; !ok && len(m.buckets) >= maxBuckets {
+		m.dropBuckets(now)
+	}
+	m.buckets[key] = tokenBucket{tokens: tokens - 1, updated: now, limit: l}
+	return true, 0, nil
+}
+
+// dropBuckets makes room for a new bucket. Full buckets are the same as no bucket, so they're dropped first.
+// If every bucket is in use, the least recently used one is dropped.
+func (m *MemoryStore) dropBuckets(now time.Time) {
+	for k, b := range m.buckets {
+		if b.limit.refill(b.tokens, b.updated, now) >= b.limit.Burst {
+			delete(m.buckets, k)
+		}
+	}
+	if len(m.buckets) < maxBuckets {
+		return
+	}
+	lru := ""
+	for k, b := range m.buckets {
+		if lru == "" || b.updated.Before(m.buckets[lru].updated) {
+			lru = k
+		}
+	}
+	delete(m.buckets, lru)
+}
+
+// copyRecord copies the record so callers can't modify stored records through shared slices.
+func copyRecord(r *Record) Record {
+	c := *r
+	c.RawPayload = append([]byte(nil), r.RawPayload...)
+	c.SlackMessages = append([]slack.MessageRef(nil), r.SlackMessages...)
+	c.Activity = append([]Activity(nil), r.Activity...)
+	c.Deliveries = append([]Delivery(nil), r.Deliveries...)
+	for i, d := range c.Deliveries {
+		c.Deliveries[i].Destinations = append([]string(nil), d.Destinations...)
+	}
+	c.History = append([]PriorSignup(nil), r.History...)
+	return c
+}
@@ -0,0 +1,580 @@
+package signups
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	// Registers the "sqlite3" database/sql driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// migrations create and update the SQLite schema. The database's user_version is the number of migrations applied.
+// Append new migrations; never edit one that has been released.
+var migrations = []func(tx *sql.Tx) error{
+	// 1: signups and their downstream deliveries
+	execSQL(`CREATE TABLE signups (
+		id             TEXT PRIMARY KEY,
+		received_at    INTEGER NOT NULL,
+		email          TEXT NOT NULL,
+		session_id     TEXT NOT NULL,
+		cohort         TEXT NOT NULL,
+		program_id     TEXT NOT NULL,
+		signup         TEXT NOT NULL,
+		raw_payload    BLOB,
+		content_type   TEXT NOT NULL DEFAULT '',
+		source_ip      TEXT NOT NULL DEFAULT '',
+		user_agent     TEXT NOT NULL DEFAULT '',
+		assigned_to    TEXT NOT NULL DEFAULT '',
+		slack_messages TEXT NOT NULL DEFAULT '[]',
+		activity       TEXT NOT NULL DEFAULT '[]'
+	);
+	CREATE INDEX signups_received_at ON signups (received_at);
+	CREATE INDEX signups_email ON signups (email COLLATE NOCASE);
+	CREATE INDEX signups_cohort ON signups (cohort COLLATE NOCASE);
+
+	CREATE TABLE deliveries (
+		signup_id TEXT NOT NULL REFERENCES signups (id) ON DELETE CASCADE,
+		seq       INTEGER NOT NULL,
+		target    TEXT NOT NULL,
+		status    TEXT NOT NULL,
+		error     TEXT NOT NULL DEFAULT '',
+		at        INTEGER NOT NULL,
+		PRIMARY KEY (signup_id, seq)
+	);`),
+
+	// 2: normalized contact info for finding repeat applicants, and their signup history
+	func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+		ALTER TABLE signups ADD COLUMN email_normalized TEXT NOT NULL DEFAULT '';
+		ALTER TABLE signups ADD COLUMN phone_normalized TEXT NOT NULL DEFAULT '';
+		ALTER TABLE signups ADD COLUMN history TEXT NOT NULL DEFAULT '[]';
+		CREATE INDEX signups_email_normalized ON signups (email_normalized);
+		CREATE INDEX signups_phone_normalized ON signups (phone_normalized);`)
+		if err != nil {
+			return err
+		}
+		return backfillApplicants(tx)
+	},
+
+	// 3: Mailgun message IDs and events of sent emails
+	execSQL(`
+	ALTER TABLE deliveries ADD COLUMN message_id TEXT NOT NULL DEFAULT '';
+	ALTER TABLE deliveries ADD COLUMN event TEXT NOT NULL DEFAULT '';
+	CREATE INDEX deliveries_message_id ON deliveries (message_id);`),
+
+	// 4: email address check results
+	execSQL(`ALTER TABLE signups ADD COLUMN email_check TEXT NOT NULL DEFAULT '{}';`),
+
+	// 5: email suppression list
+	execSQL(`CREATE TABLE suppressions (
+		email  TEXT PRIMARY KEY,
+		reason TEXT NOT NULL,
+		at     INTEGER NOT NULL
+	);`),
+
+	// 6: rate limit token buckets
+	execSQL(`CREATE TABLE rate_limits (
+		key     TEXT PRIMARY KEY,
+		tokens  REAL NOT NULL,
+		updated INTEGER NOT NULL,
+		full_at INTEGER NOT NULL
+	);
+	CREATE INDEX rate_limits_full_at ON rate_limits (full_at);`),
+
+	// 7: Slack destinations each delivery was posted to
+	execSQL(`ALTER TABLE deliveries ADD COLUMN destinations TEXT NOT NULL DEFAULT '[]';`),
+}
+
+// execSQL creates a migration that runs SQL statements.
+func execSQL(statements string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(statements)
+		return err
+	}
+}
+
+// backfillApplicants sets the normalized contact info of signups stored before migration 2.
+func backfillApplicants(tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT id, signup FROM signups")
+	if err != nil {
+		return err
+	}
+	applicants := map[string]Applicant{}
+	for rows.Next() {
+		var id, signup string
+		var s Signup
+		err := rows.Scan(&id, &signup)
+		if err == nil {
+			err = json.Unmarshal([]byte(signup), &s)
+		}
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("signup %s: %w", id, err)
+		}
+		applicants[id] = s.applicant()
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, a := range applicants {
+		_, err := tx.Exec("UPDATE signups SET email_normalized = ?, phone_normalized = ? WHERE id = ?", a.Email, a.Phone, id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SQLiteStore is a Store backed by a SQLite database file.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// OpenSQLiteStore opens (or creates) the SQLite database at path and migrates it to the latest schema.
+func OpenSQLiteStore(path string) (*SQLiteStore, error) {
+	// Transactions take the write lock when they begin, so an Update's read and save can't be interleaved with another instance's
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_foreign_keys=on&_busy_timeout=5000&_txlock=immediate", path))
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows one writer at a time
+	db.SetMaxOpenConns(1)
+
+	err = migrate(db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error migrating signup database: %w", err)
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+// Close closes the database.
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// Ping checks that the database can be reached.
+func (s *SQLiteStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// migrate applies the migrations the database hasn't run yet, each in its own transaction.
+func migrate(db *sql.DB) error {
+	var version int
+	err := db.QueryRow("PRAGMA user_version").Scan(&version)
+	if err != nil {
+		return err
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("database schema version %d is newer than this service (%d)", version, len(migrations))
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		err = migrations[i](tx)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		// PRAGMA doesn't support placeholders
+		_, err = tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1))
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		err = tx.Commit()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Save(ctx context.Context, r *Record) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = save(ctx, tx, r)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Update runs in an immediate transaction (see OpenSQLiteStore), so the record can't change between reading and saving it,
+// even from another instance sharing the database file.
+func (s *SQLiteStore) Update(ctx context.Context, id string, fn func(r *Record) error) (*Record, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	records, err := query(ctx, tx, "WHERE id = ?", 0, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, ErrNotFound
+	}
+	r := records[0]
+	err = fn(r)
+	if err != nil {
+		return nil, err
+	}
+	err = save(ctx, tx, r)
+	if err != nil {
+		return nil, err
+	}
+	return r, tx.Commit()
+}
+
+// save creates or replaces the record and its deliveries in the transaction.
+func save(ctx context.Context, tx *sql.Tx, r *Record) error {
+	signup, err := json.Marshal(r.Signup)
+	if err != nil {
+		return err
+	}
+	slackMessages, err := json.Marshal(r.SlackMessages)
+	if err != nil {
+		return err
+	}
+	activity, err := json.Marshal(r.Activity)
+	if err != nil {
+		return err
+	}
+	history, err := json.Marshal(r.History)
+	if err != nil {
+		return err
+	}
+	emailCheck, err := json.Marshal(r.EmailCheck)
+	if err != nil {
+		return err
+	}
+	a := r.Signup.applicant()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO signups (id, received_at, email, session_id, cohort, program_id, signup, raw_payload, content_type, source_ip, user_agent, assigned_to, slack_messages, activity, email_normalized, phone_normalized, history, email_check)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			received_at = excluded.received_at,
+			email = excluded.email,
+			session_id = excluded.session_id,
+			cohort = excluded.cohort,
+			program_id = excluded.program_id,
+			signup = excluded.signup,
+			raw_payload = excluded.raw_payload,
+			content_type = excluded.content_type,
+			source_ip = excluded.source_ip,
+			user_agent = excluded.user_agent,
+			assigned_to = excluded.assigned_to,
+			slack_messages = excluded.slack_messages,
+			activity = excluded.activity,
+			email_normalized = excluded.email_normalized,
+			phone_normalized = excluded.phone_normalized,
+			history = excluded.history,
+			email_check = excluded.email_check`,
+		r.Id, r.ReceivedAt.UnixNano(), r.Signup.Email, r.Signup.SessionId, r.Signup.Cohort, r.Signup.ProgramId, string(signup),
+		r.RawPayload, r.ContentType, r.SourceIP, r.UserAgent, r.AssignedTo, string(slackMessages), string(activity),
+		a.Email, a.Phone, string(history), string(emailCheck),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Deliveries are append-only on the Record, so replace them all
+	_, err = tx.ExecContext(ctx, "DELETE FROM deliveries WHERE signup_id = ?", r.Id)
+	if err != nil {
+		return err
+	}
+	for i, d := range r.Deliveries {
+		destinations, err := json.Marshal(d.Destinations)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO deliveries (signup_id, seq, target, status, error, at, message_id, event, destinations) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			r.Id, i, d.Target, d.Status, d.Error, d.At.UnixNano(), d.MessageId, d.Event, string(destinations),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Get(ctx context.Context, id string) (*Record, error) {
+	records, err := s.query(ctx, "WHERE id = ?", 0, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, ErrNotFound
+	}
+	return records[0], nil
+}
+
+func (s *SQLiteStore) Find(ctx context.Context, q Query) ([]*Record, error) {
+	where, args := q.sqlWhere()
+	records, err := s.query(ctx, where, 0, args...)
+	if err != nil || (q.Status == "" && q.ProgramId == "") {
+		return records, err
+	}
+	found := []*Record{}
+	for _, r := range records {
+		if q.matchesComputed(r) {
+			found = append(found, r)
+		}
+	}
+	return found, nil
+}
+
+// streamPageSize is how many records Stream reads at a time.
+const streamPageSize = 500
+
+// Stream calls fn with each record matching the query, oldest first, reading streamPageSize records at a time.
+func (s *SQLiteStore) Stream(ctx context.Context, q Query, fn func(*Record) error) error {
+	where, args := q.sqlWhere()
+	var after *Record
+	for {
+		pageWhere, pageArgs := where, args
+		if after != nil {
+			// Continue after the last record read, in ORDER BY received_at, id order
+			cond := "(received_at > ? OR (received_at = ? AND id > ?))"
+			if pageWhere == "" {
+				pageWhere = "WHERE " + cond
+			} else {
+				pageWhere += " AND " + cond
+			}
+			at := after.ReceivedAt.UnixNano()
+			pageArgs = append(append([]interface{}{}, args...), at, at, after.Id)
+		}
+		page, err := s.query(ctx, pageWhere, streamPageSize, pageArgs...)
+		if err != nil {
+			return err
+		}
+		for _, r := range page {
+			if !q.matchesComputed(r) {
+				continue
+			}
+			if err := fn(r); err != nil {
+				return err
+			}
+		}
+		if len(page) < streamPageSize {
+			return nil
+		}
+		after = page[len(page)-1]
+	}
+}
+
+// sqlWhere returns the WHERE clause and arguments selecting the query's records, except for the conditions matchesComputed checks.
+func (q Query) sqlWhere() (string, []interface{}) {
+	conds := []string{}
+	args := []interface{}{}
+	if q.Email != "" {
+		conds = append(conds, "email = ? COLLATE NOCASE")
+		args = append(args, q.Email)
+	}
+	if q.SessionId != "" {
+		conds = append(conds, "session_id = ?")
+		args = append(args, q.SessionId)
+	}
+	if q.Cohort != "" {
+		conds = append(conds, "cohort = ? COLLATE NOCASE")
+		args = append(args, q.Cohort)
+	}
+	if a := q.Applicant; a != (Applicant{}) {
+		// Empty values never match, so a signup without a phone number isn't linked to others without one
+		conds = append(conds, "((email_normalized = ? AND email_normalized != '') OR (phone_normalized = ? AND phone_normalized != ''))")
+		args = append(args, a.Email, a.Phone)
+	}
+	if q.MessageId != "" {
+		conds = append(conds, "id IN (SELECT signup_id FROM deliveries WHERE message_id = ?)")
+		args = append(args, q.MessageId)
+	}
+	if !q.Since.IsZero() {
+		conds = append(conds, "received_at >= ?")
+		args = append(args, q.Since.UnixNano())
+	}
+	if !q.Until.IsZero() {
+		conds = append(conds, "received_at < ?")
+		args = append(args, q.Until.UnixNano())
+	}
+	if len(conds) == 0 {
+		return "", args
+	}
+	return "WHERE " + strings.Join(conds, " AND "), args
+}
+
+// matchesComputed reports whether the record meets the query's Status and ProgramId conditions.
+// Delivery status depends on each target's latest delivery, and the program on the configured programs,
+// so they're checked here rather than in SQL.
+func (q Query) matchesComputed(r *Record) bool {
+	return q.matchesDeliveries(r) && (q.ProgramId == "" || q.ProgramId == r.Signup.Program().Id)
+}
+
+func (s *SQLiteStore) Suppress(ctx context.Context, sup *Suppression) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO suppressions (email, reason, at) VALUES (?, ?, ?)
+		ON CONFLICT (email) DO UPDATE SET reason = excluded.reason, at = excluded.at`,
+		sup.Email, sup.Reason, sup.At.UnixNano(),
+	)
+	return err
+}
+
+func (s *SQLiteStore) GetSuppression(ctx context.Context, email string) (*Suppression, error) {
+	sup := Suppression{Email: email}
+	var at int64
+	err := s.db.QueryRowContext(ctx, "SELECT reason, at FROM suppressions WHERE email = ?", email).Scan(&sup.Reason, &at)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	sup.At = time.Unix(0, at).UTC()
+	return &sup, nil
+}
+
+// Take takes a token in one statement, so instances sharing the database can't both take the last token.
+// Buckets are deleted once they'd be full again, since a full bucket is the same as no bucket.
+func (s *SQLiteStore) Take(ctx context.Context, key string, l Limit, now time.Time) (bool, time.Duration, error) {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM rate_limits WHERE full_at <= ?", now.UnixNano())
+	if err != nil {
+		return false, 0, err
+	}
+
+	// ?1 key, ?2 burst, ?3 now, ?4 tokens per nanosecond. The refilled tokens are
+	// MIN(?2, tokens + MAX(0, ?3 - updated) * ?4), and a full bucket after one token is taken is full at ?3 + 1 / ?4.
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO rate_limits (key, tokens, updated, full_at) VALUES (?1, ?2 - 1, ?3, ?3 + 1 / ?4)
+		ON CONFLICT (key) DO UPDATE SET
+			tokens = MIN(?2, tokens + MAX(0, ?3 - updated) * ?4) - 1,
+			updated = ?3,
+			full_at = ?3 + (?2 - (MIN(?2, tokens + MAX(0, ?3 - updated) * ?4) - 1)) / ?4
+		WHERE MIN(?2, tokens + MAX(0, ?3 - updated) * ?4) >= 1`,
+		key, l.Burst, now.UnixNano(), l.Rate/float64(time.Second),
+	)
+	if err != nil {
+		return false, 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, 0, err
+	}
+	if n == 1 {
+		return true, 0, nil
+	}
+
+	var tokens float64
+	var updated int64
+	err = s.db.QueryRowContext(ctx, "SELECT tokens, updated FROM rate_limits WHERE key = ?", key).Scan(&tokens, &updated)
+	if err != nil {
+		return false, 0, err
+	}
+	return false, l.wait(l.refill(tokens, time.Unix(0, updated), now)), nil
+}
+
+// query selects the records matching the WHERE clause, oldest first, with their deliveries. A limit over 0 selects at most that many.
+func (s *SQLiteStore) query(ctx context.Context, where string, limit int, args ...interface{}) ([]*Record, error) {
+	return query(ctx, s.db, where, limit, args...)
+}
+
+// queryer is a *sql.DB or *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// query selects the records matching the WHERE clause with db.
+func query(ctx context.Context, db queryer, where string, limit int, args ...interface{}) ([]*Record, error) {
+	selected := where + " ORDER BY received_at, id"
+	if limit > 0 {
+		selected += fmt.Sprintf(" LIMIT %d", limit)
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, received_at, signup, raw_payload, content_type, source_ip, user_agent, assigned_to, slack_messages, activity, history, email_check
+		FROM signups `+selected, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []*Record{}
+	byId := map[string]*Record{}
+	for rows.Next() {
+		var r Record
+		var receivedAt int64
+		var signup, slackMessages, activity, history, emailCheck string
+		err := rows.Scan(&r.Id, &receivedAt, &signup, &r.RawPayload, &r.ContentType, &r.SourceIP, &r.UserAgent, &r.AssignedTo, &slackMessages, &activity, &history, &emailCheck)
+		if err != nil {
+			return nil, err
+		}
+		r.ReceivedAt = time.Unix(0, receivedAt).UTC()
+		if err := json.Unmarshal([]byte(signup), &r.Signup); err != nil {
+			return nil, fmt.Errorf("signup %s: %w", r.Id, err)
+		}
+		if err := json.Unmarshal([]byte(slackMessages), &r.SlackMessages); err != nil {
+			return nil, fmt.Errorf("signup %s slack_messages: %w", r.Id, err)
+		}
+		if err := json.Unmarshal([]byte(activity), &r.Activity); err != nil {
+			return nil, fmt.Errorf("signup %s activity: %w", r.Id, err)
+		}
+		if err := json.Unmarshal([]byte(history), &r.History); err != nil {
+			return nil, fmt.Errorf("signup %s history: %w", r.Id, err)
+		}
+		if err := json.Unmarshal([]byte(emailCheck), &r.EmailCheck); err != nil {
+			return nil, fmt.Errorf("signup %s email_check: %w", r.Id, err)
+		}
+		records = append(records, &r)
+		byId[r.Id] = &r
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// Release the connection for the deliveries query
+	rows.Close()
+	if len(records) == 0 {
+		return records, nil
+	}
+
+	// Attach deliveries to the selected signups
+	deliveries, err := db.QueryContext(ctx, `
+		SELECT signup_id, target, status, error, at, message_id, event, destinations FROM deliveries
+		WHERE signup_id IN (SELECT id FROM signups `+selected+`)
+		ORDER BY signup_id, seq`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer deliveries.Close()
+	for deliveries.Next() {
+		var id string
+		var d Delivery
+		var at int64
+		var destinations string
+		err := deliveries.Scan(&id, &d.Target, &d.Status, &d.Error, &at, &d.MessageId, &d.Event, &destinations)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(destinations), &d.Destinations); err != nil {
+			return nil, fmt.Errorf("signup %s destinations: %w", id, err)
+		}
+		d.At = time.Unix(0, at).UTC()
+		if r, ok := byId[id]; ok {
+			r.Deliveries = append(r.Deliveries, d)
+		}
+	}
+	return records, deliveries.Err()
+}
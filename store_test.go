@@ -0,0 +1,352 @@
+package signups
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/operationspark/slack-session-signups/email"
+	"github.com/operationspark/slack-session-signups/slack"
+)
+
+func TestStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"memory": func(t *testing.T) Store { return NewMemoryStore() },
+		"sqlite": func(t *testing.T) Store {
+			s, err := OpenSQLiteStore(filepath.Join(t.TempDir(), "signups.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { s.Close() })
+			return s
+		},
+	}
+
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			testStore(t, open(t))
+		})
+	}
+}
+
+// testStore checks the behavior every Store implementation shares.
+func testStore(t *testing.T, store Store) {
+	ctx := context.Background()
+	received, _ := time.Parse(time.RFC3339, "2022-03-10T18:00:00Z")
+	sessionStart, _ := time.Parse(time.RFC3339, "2022-03-14T17:00:00Z")
+
+	henri := &Record{
+		Id:          "henri",
+		Signup:      Signup{NameFirst: "Henri", NameLast: "Testaroni", Email: "henri@email.com", Cell: "(504) 555-0100", SessionId: "s1", Cohort: "is-mar-14-22-12pm", StartDateTime: sessionStart},
+		ReceivedAt:  received,
+		RawPayload:  []byte(`{"nameFirst": "Henri"}`),
+		ContentType: "application/json",
+		SourceIP:    "203.0.113.7",
+		UserAgent:   "Mozilla/5.0",
+	}
+	solana := &Record{
+		Id:         "solana",
+		Signup:     Signup{NameFirst: "Solána", NameLast: "Rowe", Email: "solana@email.com"},
+		ReceivedAt: received.Add(-48 * time.Hour),
+	}
+	solana.recordEmail("20220314170000.1@mail.operationspark.org", nil)
+	solana.Deliveries[0].Event = "opened"
+	for _, r := range []*Record{henri, solana} {
+		if err := store.Save(ctx, r); err != nil {
+			t.Fatalf("Save(%s): %s", r.Id, err)
+		}
+	}
+
+	// Update a record
+	henri.record(TargetGreenlight, nil)
+	henri.record(TargetEmail, errors.New("mailgun is down"))
+	henri.SlackMessages = []slack.MessageRef{{Channel: "C0SIGNUPS", TS: "1650000000.000001"}}
+	henri.Activity = []Activity{{Action: "marked contacted", UserId: "U0STAFF", At: received.Add(time.Hour)}}
+	henri.AssignedTo = "U0STAFF"
+	henri.EmailCheck = email.Check{Suggestion: "henri@gmail.com", NoMailServer: true}
+	henri.History = []PriorSignup{{Id: "henri-0", Cohort: "is-feb-28-22-12pm", ReceivedAt: received.Add(-240 * time.Hour), Attended: "unknown", Emailed: true}}
+	if err := store.Save(ctx, henri); err != nil {
+		t.Fatalf("Save(henri) update: %s", err)
+	}
+
+	got, err := store.Get(ctx, "henri")
+	if err != nil {
+		t.Fatalf("Get: %s", err)
+	}
+	if diff := cmp.Diff(henri, got); diff != "" {
+		t.Errorf("Get() mismatch (-want +got):\n%s", diff)
+	}
+	if failed := got.Failed(); len(failed) != 1 || failed[0] != TargetEmail {
+		t.Errorf("want failed email delivery, got %v", failed)
+	}
+
+	got, err = store.Get(ctx, "solana")
+	if err != nil {
+		t.Fatalf("Get: %s", err)
+	}
+	if diff := cmp.Diff(solana.Deliveries, got.Deliveries); diff != "" {
+		t.Errorf("Get() deliveries mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := store.Get(ctx, "nobody"); err != ErrNotFound {
+		t.Errorf("Get(nobody): want ErrNotFound, got %v", err)
+	}
+
+	// Update saves the changes, unless fn fails
+	updated, err := store.Update(ctx, "solana", func(r *Record) error {
+		r.AssignedTo = "U0STAFF"
+		return nil
+	})
+	if err != nil || updated.AssignedTo != "U0STAFF" {
+		t.Fatalf("Update(solana) = %+v, %v", updated, err)
+	}
+	errStop := errors.New("stop")
+	if _, err := store.Update(ctx, "solana", func(r *Record) error {
+		r.AssignedTo = "U0OTHER"
+		return errStop
+	}); err != errStop {
+		t.Errorf("Update(solana): want fn's error, got %v", err)
+	}
+	if got, _ := store.Get(ctx, "solana"); got.AssignedTo != "U0STAFF" {
+		t.Errorf("want a failed Update not saved, got assigned to %q", got.AssignedTo)
+	}
+	if _, err := store.Update(ctx, "nobody", func(r *Record) error { return nil }); err != ErrNotFound {
+		t.Errorf("Update(nobody): want ErrNotFound, got %v", err)
+	}
+
+	// Suppressions are replaced, not duplicated
+	bounced := &Suppression{Email: "henri@email.com", Reason: SuppressBounced, At: received}
+	unsubscribed := &Suppression{Email: "henri@email.com", Reason: SuppressUnsubscribed, At: received.Add(time.Hour)}
+	for _, s := range []*Suppression{bounced, unsubscribed} {
+		if err := store.Suppress(ctx, s); err != nil {
+			t.Fatalf("Suppress: %s", err)
+		}
+	}
+	sup, err := store.GetSuppression(ctx, "henri@email.com")
+	if err != nil {
+		t.Fatalf("GetSuppression: %s", err)
+	}
+	if diff := cmp.Diff(unsubscribed, sup); diff != "" {
+		t.Errorf("GetSuppression() mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := store.GetSuppression(ctx, "solana@email.com"); err != ErrNotFound {
+		t.Errorf("GetSuppression(solana): want ErrNotFound, got %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{"all, oldest first", Query{}, []string{"solana", "henri"}},
+		{"email is case-insensitive", Query{Email: "HENRI@email.com"}, []string{"henri"}},
+		{"session", Query{SessionId: "s1"}, []string{"henri"}},
+		{"cohort", Query{Cohort: "IS-MAR-14-22-12PM"}, []string{"henri"}},
+		{"default program", Query{ProgramId: DefaultProgramId}, []string{"solana", "henri"}},
+		{"program", Query{ProgramId: "workshop"}, []string{}},
+		{"since", Query{Since: received}, []string{"henri"}},
+		{"until is exclusive", Query{Until: received}, []string{"solana"}},
+		{"applicant by normalized email", Query{Applicant: Applicant{Email: "henri@email.com"}}, []string{"henri"}},
+		{"applicant by phone", Query{Applicant: Applicant{Email: "other@email.com", Phone: "5045550100"}}, []string{"henri"}},
+		{"applicant without phone", Query{Applicant: Applicant{Email: "other@email.com"}}, []string{}},
+		{"email message ID", Query{MessageId: "20220314170000.1@mail.operationspark.org"}, []string{"solana"}},
+		{"unknown message ID", Query{MessageId: "20220314170000.2@mail.operationspark.org"}, []string{}},
+		{"no match", Query{Email: "nobody@email.com"}, []string{}},
+	}
+	for _, test := range tests {
+		records, err := store.Find(ctx, test.query)
+		if err != nil {
+			t.Fatalf("%s: Find: %s", test.name, err)
+		}
+		ids := []string{}
+		for _, r := range records {
+			ids = append(ids, r.Id)
+		}
+		if diff := cmp.Diff(test.want, ids); diff != "" {
+			t.Errorf("%s: Find() mismatch (-want +got):\n%s", test.name, diff)
+		}
+	}
+}
+
+func TestStoreConcurrentUpdates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signups.db")
+	openSQLite := func() Store {
+		s, err := OpenSQLiteStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Close() })
+		return s
+	}
+	memory := NewMemoryStore()
+	sqlite := openSQLite()
+	stores := map[string][]Store{
+		"memory": {memory},
+		"sqlite": {sqlite},
+		// Instances sharing the database file
+		"sqlite instances": {sqlite, openSQLite()},
+	}
+
+	for name, instances := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			rec := newRecord(Signup{NameFirst: "Henri", Email: "henri@email.com"})
+			if err := instances[0].Save(ctx, rec); err != nil {
+				t.Fatal(err)
+			}
+
+			const updates = 20
+			errs := make(chan error, updates)
+			for i := 0; i < updates; i++ {
+				go func(i int) {
+					_, err := instances[i%len(instances)].Update(ctx, rec.Id, func(r *Record) error {
+						r.Activity = append(r.Activity, Activity{Action: "marked contacted", Note: strconv.Itoa(i)})
+						return nil
+					})
+					errs <- err
+				}(i)
+			}
+			for i := 0; i < updates; i++ {
+				if err := <-errs; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := instances[0].Get(ctx, rec.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got.Activity) != updates {
+				t.Errorf("want every update kept, got %d of %d activities", len(got.Activity), updates)
+			}
+		})
+	}
+}
+
+func TestSQLiteMigrations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signups.db")
+	for i := 0; i < 2; i++ {
+		s, err := OpenSQLiteStore(path)
+		if err != nil {
+			t.Fatalf("open %d: %s", i, err)
+		}
+		var version int
+		if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+			t.Fatal(err)
+		}
+		if version != len(migrations) {
+			t.Errorf("want schema version %d, got %d", len(migrations), version)
+		}
+		s.Close()
+	}
+}
+
+func TestSQLiteBackfillsApplicants(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signups.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A database created before migration 2
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrations[0](tx); err != nil {
+		t.Fatal(err)
+	}
+	_, err = tx.Exec(`INSERT INTO signups (id, received_at, email, session_id, cohort, program_id, signup) VALUES ('henri', 0, 'Henri.T+info@gmail.com', '', '', '', '{"email": "Henri.T+info@gmail.com", "cell": "+1 504-555-0100"}')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("PRAGMA user_version = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	s, err := OpenSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	records, err := s.Find(context.Background(), Query{Applicant: Applicant{Email: "henrit@gmail.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Id != "henri" {
+		t.Errorf("want backfilled signup 'henri', got %v", records)
+	}
+}
+
+func TestMemoryStoreLimits(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryStore()
+	start := time.Unix(1650000000, 0)
+	for i := 0; i <= maxMemoryRecords; i++ {
+		rec := &Record{Id: newId(), ReceivedAt: start.Add(time.Duration(i) * time.Minute)}
+		if err := m.Save(ctx, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records, _ := m.Find(ctx, Query{})
+	if len(records) != maxMemoryRecords || !records[0].ReceivedAt.Equal(start.Add(time.Minute)) {
+		t.Errorf("want the newest %d records, got %d from %s", maxMemoryRecords, len(records), records[0].ReceivedAt)
+	}
+
+	// Every bucket is in use, so the least recently used is dropped for a new one
+	l := Limit{Rate: 1.0 / 86400, Burst: 1}
+	for i := 0; i <= maxBuckets; i++ {
+		m.Take(ctx, "ip:"+strconv.Itoa(i), l, start.Add(time.Duration(i)*time.Second))
+	}
+	if len(m.buckets) != maxBuckets {
+		t.Errorf("want %d buckets, got %d", maxBuckets, len(m.buckets))
+	}
+	if _, ok := m.buckets["ip:0"]; ok {
+		t.Error("want the least recently used bucket dropped")
+	}
+}
+
+func TestSQLiteStream(t *testing.T) {
+	ctx := context.Background()
+	s, err := OpenSQLiteStore(filepath.Join(t.TempDir(), "signups.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// More than a page, with some received at the same time so the page boundary falls between them
+	start := time.Unix(1650000000, 0).UTC()
+	want := []string{}
+	for i := 0; i < streamPageSize+10; i++ {
+		rec := &Record{Id: fmt.Sprintf("%04d", i), Signup: Signup{Cohort: "is-mar-14-22-12pm"}, ReceivedAt: start.Add(time.Duration(i/3) * time.Second)}
+		rec.record(TargetSlack, nil)
+		if err := s.Save(ctx, rec); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, rec.Id)
+	}
+	if err := s.Save(ctx, &Record{Id: "other", Signup: Signup{Cohort: "ws-mar-12-22-10am"}, ReceivedAt: start}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := []string{}
+	err = s.Stream(ctx, Query{Cohort: "is-mar-14-22-12pm", Status: StatusDelivered}, func(r *Record) error {
+		got = append(got, r.Id)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Stream() mismatch (-want +got):\n%s", diff)
+	}
+}
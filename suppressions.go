@@ -0,0 +1,145 @@
+package signups
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// UNSUBSCRIBE_URL is the public URL of the /unsubscribe endpoint, linked in every email's List-Unsubscribe header.
+// UNSUBSCRIBE_SECRET signs the links. Emails don't get the header unless both are set.
+var UNSUBSCRIBE_URL = os.Getenv("UNSUBSCRIBE_URL")
+var UNSUBSCRIBE_SECRET = os.Getenv("UNSUBSCRIBE_SECRET")
+
+// Suppression reasons.
+const (
+	SuppressUnsubscribed = "unsubscribed"
+	SuppressBounced      = "bounced"
+	SuppressComplained   = "complained"
+)
+
+// Suppression stops emails to an address, except the welcome email someone asks for by signing up.
+type Suppression struct {
+	// Email is the suppressed address, normalized like Applicant emails so "Henri+info@Gmail.com" is suppressed with "henri@gmail.com".
+	Email string
+	// Reason is why the address is suppressed: it unsubscribed, hard-bounced, or marked an email as spam.
+	Reason string
+	At     time.Time
+}
+
+// ErrSuppressed is returned when an email isn't sent because the address is on the suppression list.
+var ErrSuppressed = errors.New("email address is suppressed")
+
+// Kinds of email, which decide whether an email can be sent to a suppressed address.
+const (
+	// emailConfirmation is the welcome email sent when someone signs up. It's sent to suppressed addresses too, since they just asked for it.
+	emailConfirmation = "confirmation"
+	// emailFollowUp is any other email, e.g. a welcome email resent by staff.
+	emailFollowUp = "follow-up"
+)
+
+// suppress adds the address to the suppression list.
+func suppress(ctx context.Context, address, reason string) error {
+	return signupStore.Suppress(ctx, &Suppression{Email: normalizeEmail(address), Reason: reason, At: time.Now().UTC()})
+}
+
+// checkSuppression is consulted before every email is sent. It returns an ErrSuppressed error if the address is suppressed and the kind of email isn't allowed.
+func checkSuppression(ctx context.Context, address, kind string) error {
+	if kind == emailConfirmation {
+		return nil
+	}
+	s, err := signupStore.GetSuppression(ctx, normalizeEmail(address))
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		// The list couldn't be checked, so don't risk emailing someone who unsubscribed
+		return fmt.Errorf("error checking suppression list: %w", err)
+	}
+	return fmt.Errorf("%w: %s %s", ErrSuppressed, address, s.Reason)
+}
+
+// unsubscribeToken signs the address, so unsubscribe links can't be made for other addresses.
+func unsubscribeToken(address string) string {
+	mac := hmac.New(sha256.New, []byte(UNSUBSCRIBE_SECRET))
+	mac.Write([]byte(normalizeEmail(address)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// unsubscribeHeaders returns the List-Unsubscribe headers for an email to the address, with a one-click (RFC 8058) unsubscribe link.
+// It returns nil if UNSUBSCRIBE_URL or UNSUBSCRIBE_SECRET isn't set.
+func unsubscribeHeaders(address string) map[string]string {
+	if UNSUBSCRIBE_URL == "" || UNSUBSCRIBE_SECRET == "" {
+		return nil
+	}
+	link := UNSUBSCRIBE_URL + "?" + url.Values{"email": {address}, "token": {unsubscribeToken(address)}}.Encode()
+	return map[string]string{
+		"List-Unsubscribe":      "<" + link + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+}
+
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Unsubscribe - Operation Spark</title></head>
+<body style="font-family: sans-serif; max-width: 32em; margin: 4em auto;">
+{{if .Unsubscribed}}
+<p>{{.Email}} has been unsubscribed from Operation Spark emails.</p>
+{{else}}
+<form method="post">
+<p>Stop sending Operation Spark emails to {{.Email}}?</p>
+<button type="submit">Unsubscribe</button>
+</form>
+{{end}}
+</body>
+</html>
+`))
+
+// HandleUnsubscribe unsubscribes the email address in a signed link from List-Unsubscribe headers.
+// Mail clients POST to the link to unsubscribe in one click. Opening the link (GET) asks to confirm first,
+// so link scanners don't unsubscribe anybody.
+func HandleUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	if UNSUBSCRIBE_SECRET == "" {
+		http.NotFound(w, r)
+		return
+	}
+	address := r.URL.Query().Get("email")
+	token := r.URL.Query().Get("token")
+	if address == "" || !hmac.Equal([]byte(token), []byte(unsubscribeToken(address))) {
+		http.Error(w, "Invalid unsubscribe link", http.StatusForbidden)
+		return
+	}
+
+	page := struct {
+		Email        string
+		Unsubscribed bool
+	}{Email: address}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		err := suppress(r.Context(), address, SuppressUnsubscribed)
+		if err != nil {
+			http.Error(w, "Error unsubscribing, please try again", http.StatusInternalServerError)
+			fmt.Printf("error unsubscribing %s %s\n", address, err.Error())
+			return
+		}
+		page.Unsubscribed = true
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := unsubscribePage.Execute(w, page)
+	if err != nil {
+		fmt.Printf("error writing unsubscribe page %s\n", err.Error())
+	}
+}
@@ -0,0 +1,82 @@
+package signups
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestHandleUnsubscribe(t *testing.T) {
+	defer setVar(&UNSUBSCRIBE_URL, "https://signups.example.com/unsubscribe")()
+	defer setVar(&UNSUBSCRIBE_SECRET, "unsubscribe-secret")()
+	defer setStore(NewMemoryStore())()
+	ctx := context.Background()
+
+	headers := unsubscribeHeaders("Henri+info@Gmail.com")
+	if headers["List-Unsubscribe-Post"] != "List-Unsubscribe=One-Click" {
+		t.Errorf("want a one-click unsubscribe header, got %v", headers)
+	}
+	link := strings.Trim(headers["List-Unsubscribe"], "<>")
+	u, err := url.Parse(link)
+	if err != nil || u.Host != "signups.example.com" || u.Query().Get("email") != "Henri+info@Gmail.com" {
+		t.Fatalf("want a signed link to the unsubscribe endpoint, got %q", link)
+	}
+	forged := strings.Replace(link, "Henri%2Binfo%40Gmail.com", "solana%40email.com", 1)
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		wantStatus int
+		wantBody   string
+	}{
+		{"forged link", http.MethodPost, forged, http.StatusForbidden, "Invalid unsubscribe link"},
+		{"missing token", http.MethodPost, UNSUBSCRIBE_URL + "?email=henri%40gmail.com", http.StatusForbidden, "Invalid unsubscribe link"},
+		// Opening the link asks to confirm, so link scanners don't unsubscribe anybody
+		{"open link", http.MethodGet, link, http.StatusOK, `<form method="post">`},
+		{"one-click", http.MethodPost, link, http.StatusOK, "Henri&#43;info@Gmail.com has been unsubscribed"},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.url, strings.NewReader("List-Unsubscribe=One-Click"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		HandleUnsubscribe(w, req)
+		if w.Code != test.wantStatus || !strings.Contains(w.Body.String(), test.wantBody) {
+			t.Errorf("%s: want %d %q, got %d %q", test.name, test.wantStatus, test.wantBody, w.Code, w.Body)
+		}
+
+		if test.name == "open link" {
+			if _, err := signupStore.GetSuppression(ctx, "henri@gmail.com"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("want no suppression before confirming, got %v", err)
+			}
+		}
+	}
+
+	s, err := signupStore.GetSuppression(ctx, "henri@gmail.com")
+	if err != nil || s.Reason != SuppressUnsubscribed {
+		t.Fatalf("want henri@gmail.com unsubscribed, got %+v %v", s, err)
+	}
+
+	// Only the welcome email someone asks for by signing up is sent to a suppressed address
+	if err := checkSuppression(ctx, "henri@gmail.com", emailConfirmation); err != nil {
+		t.Errorf("want the confirmation allowed, got %v", err)
+	}
+	if err := checkSuppression(ctx, "h.e.n.r.i@gmail.com", emailFollowUp); !errors.Is(err, ErrSuppressed) {
+		t.Errorf("want follow-up emails suppressed, got %v", err)
+	}
+	if err := checkSuppression(ctx, "solana@email.com", emailFollowUp); err != nil {
+		t.Errorf("want other addresses allowed, got %v", err)
+	}
+}
+
+func TestUnsubscribeHeadersNotConfigured(t *testing.T) {
+	defer setVar(&UNSUBSCRIBE_URL, "https://signups.example.com/unsubscribe")()
+	defer setVar(&UNSUBSCRIBE_SECRET, "")()
+	if headers := unsubscribeHeaders("henri@email.com"); headers != nil {
+		t.Errorf("want no headers without UNSUBSCRIBE_SECRET, got %v", headers)
+	}
+}
@@ -0,0 +1,45 @@
+package signups
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/template"
+)
+
+// emailTemplates holds every email template the service can send, keyed by name.
+// A template's sources are parsed in order, so later sources can redefine blocks from earlier ones.
+var emailTemplates = map[string][]string{
+	"info-session-welcome": {InfoSessionHtml},
+	"workshop-welcome":     {InfoSessionHtml, WorkshopHtml},
+}
+
+// EmailTemplateNames returns the names of all registered email templates in alphabetical order.
+func EmailTemplateNames() []string {
+	names := make([]string, 0, len(emailTemplates))
+	for name := range emailTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RenderEmail populates the named email template with values from the Signup. It then writes the result to the io.Writer, w.
+func RenderEmail(w io.Writer, name string, s Signup) error {
+	sources, ok := emailTemplates[name]
+	if !ok {
+		return fmt.Errorf("unknown email template: '%s'", name)
+	}
+	t := template.New(name)
+	for _, src := range sources {
+		_, err := t.Parse(src)
+		if err != nil {
+			return err
+		}
+	}
+	data, err := s.WelcomeData()
+	if err != nil {
+		return err
+	}
+	return t.Execute(w, data)
+}
@@ -0,0 +1,22 @@
+package signups
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/operationspark/slack-session-signups/email"
+)
+
+// emailChecker looks for typos and undeliverable domains in signup email addresses.
+var emailChecker = email.NewChecker(net.DefaultResolver)
+
+// checkEmail checks a Signup's email address for typos and domains that can't receive email.
+// The address is still used; the problems are shown to the applicant and staff.
+func (s *Signup) checkEmail(ctx context.Context) email.Check {
+	check, err := emailChecker.Check(ctx, s.Email)
+	if err != nil {
+		fmt.Printf("error checking email address %s %s\n", s.Email, err.Error())
+	}
+	return check
+}
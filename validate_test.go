@@ -0,0 +1,40 @@
+package signups
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/operationspark/slack-session-signups/email"
+)
+
+// TestMain keeps the tests off the network: email addresses are checked against testResolver instead of real DNS.
+func TestMain(m *testing.M) {
+	emailChecker = email.NewChecker(testResolver)
+	os.Exit(m.Run())
+}
+
+// testResolver has mail servers for the domains test signups use.
+var testResolver = mxResolver{
+	"email.com": {{Host: "mx.email.com.", Pref: 10}},
+	"gmail.com": {{Host: "gmail-smtp-in.l.google.com.", Pref: 5}},
+}
+
+// mxResolver answers MX lookups from a map. Domains that aren't in it don't exist.
+type mxResolver map[string][]*net.MX
+
+func (m mxResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	mx, ok := m[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return mx, nil
+}
+
+// setChecker replaces the email checker for the duration of a test. Defer the returned func to restore it.
+func setChecker(c *email.Checker) func() {
+	prev := emailChecker
+	emailChecker = c
+	return func() { emailChecker = prev }
+}
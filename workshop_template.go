@@ -0,0 +1,50 @@
+package signups
+
+// WorkshopHtml overrides the copy in the Info Session Welcome email layout for Workshop signups.
+// It is parsed after InfoSessionHtml, which defines the "title", "preheader", "content", and "permission" blocks.
+const WorkshopHtml = `
+{{define "title"}}Workshop Confirmation{{end}}
+
+{{define "preheader"}}Thanks for signing up for an upcoming {{.ProgramName}}.{{end}}
+
+{{define "content"}}<p>Hi {{.DisplayName}},</p>
+
+                {{ if eq .SessionDate "" }}
+
+                <p>
+                  Thank you for your interest in the {{.ProgramName}}. We don't
+                  have a date that fits your schedule yet, but we'll be reaching
+                  out soon with upcoming times.
+                </p>
+
+                {{ else }}
+                <p>
+                  Thank you for registering for the {{.ProgramName}} with
+                  Operation Spark. We're looking forward to seeing you on
+                  {{.SessionDate}} at {{.SessionTime}}.
+                </p>
+
+                <p>
+                  You will receive a detailed email from our team prior to the
+                  workshop with everything you need to get set up and
+                  instructions for joining.
+                </p>
+
+                {{ end }}
+
+                <p>
+                  In the meantime, if you have any questions please reply to
+                  this email or reach out to us at
+                  <a href="mailto: admissions@operationspark.org"
+                    >admissions@operationspark.org</a
+                  >
+                </p>
+                <p>
+                  Thank you for your interest and we look forward to meeting
+                  soon.
+                </p>{{end}}
+
+{{define "permission"}}You received this email because we received a request to join
+                  a {{.ProgramName}}. If you didn't request to join a {{.ProgramName}}
+                  you can safely delete this email.{{end}}
+`